@@ -4,6 +4,7 @@ import (
 	"context"
 	"idcard/internal/config"
 	"idcard/internal/handler"
+	"idcard/internal/migrate"
 	"idcard/internal/repository"
 	"idcard/internal/service"
 	"log"
@@ -32,35 +33,96 @@ func main() {
 	}
 	defer config.CloseDB()
 
-	// if err := migrate.CreateTable(db); err != nil {
-	// 	log.Fatal(err)
-	// }
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatal("[Migrate]", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "normalize-phones" {
+		if err := runNormalizePhones(db, os.Args[2:]); err != nil {
+			log.Fatal("[Phones]", err)
+		}
+		return
+	}
+
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		if _, err := migrate.Up(context.Background(), db); err != nil {
+			log.Fatal("[Migrate]", err)
+		}
+	}
 	storageCfg, err := config.LoadStorageConfig()
 	if err != nil {
 		log.Println("Error loading App Config:", err)
 		return
 	}
 
-	s3Client, err := config.InitStorageClient(context.Background(),
-		storageCfg.CloudflareAccessKey,
-		storageCfg.CloudflareSecretKey,
-		storageCfg.CloudflareEndpoint,
-	)
+	ids, err := config.LoadIDScheme()
+	if err != nil {
+		log.Println("Error loading ID scheme:", err)
+		return
+	}
+
+	validity, err := config.LoadCardValidity()
+	if err != nil {
+		log.Println("Error loading card validity:", err)
+		return
+	}
+
+	cardCodes, err := config.LoadCardCode()
+	if err != nil {
+		log.Println("Error loading card code key:", err)
+		return
+	}
+
+	cardBack, err := config.LoadCardBack()
+	if err != nil {
+		log.Println("Error loading card back:", err)
+		return
+	}
+
+	verifyLimit, err := config.LoadVerifyRateLimit()
+	if err != nil {
+		log.Println("Error loading verification rate limit:", err)
+		return
+	}
+
+	ratingFormula, err := config.LoadRatingFormula()
+	if err != nil {
+		log.Println("Error loading QC rating formula:", err)
+		return
+	}
+
+	storage, err := config.NewClient(context.Background(), storageCfg)
 	if err != nil {
 		log.Fatal("Failed to initialize storage client:", err)
 		return
 	}
-	storage := config.NewStorageClient(s3Client, storageCfg.CloudflareBucket)
+	log.Printf("[Storage]Using %s storage driver", storageCfg.Driver)
 
 	// Set up HTTP routes and handlers
 	http.Handle("/static/", withCORS(http.StripPrefix("/static/", http.FileServer(http.Dir("static")))))
-	http.Handle("/pdf/", http.StripPrefix("/pdf/", http.FileServer(http.Dir("pdf"))))
+	if local, ok := storage.(interface{ Handler() http.Handler }); ok {
+		http.Handle(config.LocalStorageRoute, withCORS(http.StripPrefix(config.LocalStorageRoute, local.Handler())))
+	}
 
 	userRepo := repository.NewUserRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	blacklistRepo := repository.NewBlacklistRepository(db)
+	inspectionRepo := repository.NewInspectionRepository(db)
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	holderTypeRepo := repository.NewHolderTypeRepository(db)
+	holderTypeSvc := service.NewHolderTypeService(holderTypeRepo, ids)
+	inspectionSvc := service.NewInspectionService(inspectionRepo, userRepo, auditRepo, ratingFormula)
 	pdfSvc := service.NewPdfService()
 	exclSvc := service.NewExcelService()
-	userService := service.NewUserService(userRepo, pdfSvc, exclSvc, storage)
-	userHandler := handler.NewUserHandler(userService)
+	userService := service.NewUserService(userRepo, auditRepo, blacklistRepo, holderTypeSvc, pdfSvc, exclSvc, storage, ids, validity, cardCodes, cardBack, inspectionRepo, ratingFormula, deliveryRepo)
+	deliverySvc := service.NewDeliveryService(deliveryRepo, userRepo, inspectionRepo, auditRepo, ratingFormula, ids, exclSvc)
+
+	userHandler := handler.NewUserHandler(userService, holderTypeSvc, ids)
+	holderTypeHandler := handler.NewHolderTypeHandler(holderTypeSvc)
+	inspectionHandler := handler.NewInspectionHandler(inspectionSvc, userService, ids, ratingFormula.GradeNames())
+	deliveryHandler := handler.NewDeliveryHandler(deliverySvc, ids, ratingFormula.GradeNames())
 
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -69,9 +131,39 @@ func main() {
 	// "/" Page
 	http.HandleFunc("/", userHandler.IndexHandler)
 	http.HandleFunc("/get", userHandler.GetUserHandler)
+	http.HandleFunc("/users", userHandler.ListUsersHandler)
+	http.HandleFunc("/users/search", userHandler.SearchUsersHandler)
+	http.HandleFunc("/reports/demographics", userHandler.DemographicsHandler)
 	http.HandleFunc("/get-id", userHandler.GetIdHandler)
 	http.HandleFunc("/create", userHandler.CreateUserHandler)
 	http.HandleFunc("/update", userHandler.UpdateUserHandler)
+	http.HandleFunc("/delete", userHandler.DeleteUserHandler)
+	http.HandleFunc("/restore", userHandler.RestoreUserHandler)
+	http.HandleFunc("/duplicates", userHandler.DuplicatesHandler)
+	http.HandleFunc("/holder-types", holderTypeHandler.PageHandler)
+	http.HandleFunc("/holder-types/list", holderTypeHandler.ListHandler)
+	http.HandleFunc("/holder-types/save", holderTypeHandler.SaveHandler)
+	http.HandleFunc("/holder-types/delete", holderTypeHandler.DeleteHandler)
+	http.HandleFunc("/merge", userHandler.MergeUserHandler)
+	http.HandleFunc("/renew", userHandler.RenewUserHandler)
+	http.HandleFunc("/state", userHandler.ChangeStateHandler)
+	http.HandleFunc("/scan", userHandler.ScanHandler)
+	// Card numbers are sequential, so both ways of checking one share a
+	// limit against walking through them
+	cardLimiter := handler.NewRateLimiter(verifyLimit)
+	http.HandleFunc("/lookup", cardLimiter.Limit(userHandler.LookupHandler))
+	// Public, no login: guards and partner mills check cards here
+	http.HandleFunc("GET /verify", userHandler.VerifyFormHandler)
+	http.HandleFunc("GET /verify/{id}", cardLimiter.Limit(userHandler.VerifyPageHandler))
+	http.HandleFunc("/holder", inspectionHandler.PageHandler)
+	http.HandleFunc("/inspections", inspectionHandler.ListHandler)
+	http.HandleFunc("/inspections/add", inspectionHandler.AddHandler)
+	http.HandleFunc("/deliveries", deliveryHandler.ListHandler)
+	http.HandleFunc("/deliveries/view", deliveryHandler.PageHandler)
+	http.HandleFunc("/deliveries/add", deliveryHandler.AddHandler)
+	http.HandleFunc("/deliveries/export", deliveryHandler.ExportHandler)
+	http.HandleFunc("/history", userHandler.HistoryHandler)
+	http.HandleFunc("/history/view", userHandler.HistoryPageHandler)
 
 	// "/upload" Page
 	http.HandleFunc("/upload", userHandler.UploadRedirecthandler)
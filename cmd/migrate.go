@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/migrate"
+	"idcard/internal/util"
+	"os"
+	"text/tabwriter"
+)
+
+// runMigrate handles `idcard migrate up|down [n]|status`.
+func runMigrate(db config.DB, args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: idcard migrate up|down [n]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrate.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps = util.ParseInt(args[1])
+		}
+		if steps < 1 {
+			return fmt.Errorf("invalid number of steps: %s", args[1])
+		}
+		n, err := migrate.Down(ctx, db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+	case "status":
+		statuses, err := migrate.Statuses(ctx, db)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+	return nil
+}
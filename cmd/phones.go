@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"idcard/internal/service"
+	"sort"
+)
+
+// runNormalizePhones handles `idcard normalize-phones [--dry-run]`.
+func runNormalizePhones(db config.DB, args []string) error {
+	dryRun := false
+	for _, a := range args {
+		switch a {
+		case "--dry-run", "-n":
+			dryRun = true
+		default:
+			return fmt.Errorf("usage: idcard normalize-phones [--dry-run]")
+		}
+	}
+
+	ctx := service.WithActor(context.Background(), model.Actor{Operator: "normalize-phones", Source: model.SourceCLI})
+	res, err := service.NormalizePhones(ctx, repository.NewUserRepository(db), repository.NewAuditRepository(db), dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "normalized"
+	if dryRun {
+		verb = "would normalize"
+	}
+	fmt.Printf("checked %d phone(s), %s %d\n", res.Checked, verb, res.Changed)
+
+	ids := make([]string, 0, len(res.Invalid))
+	for id := range res.Invalid {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("invalid %s: %s\n", id, res.Invalid[id])
+	}
+	return nil
+}
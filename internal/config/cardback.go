@@ -0,0 +1,18 @@
+package config
+
+import "strings"
+
+const EnvCardEmergencyContact = "CARD_EMERGENCY_CONTACT"
+
+type (
+	// CardBack is what the back of every card carries besides its rules.
+	CardBack struct {
+		EmergencyContact string
+	}
+)
+
+func LoadCardBack() (*CardBack, error) {
+	return &CardBack{
+		EmergencyContact: strings.TrimSpace(getEnv(EnvCardEmergencyContact, "")),
+	}, nil
+}
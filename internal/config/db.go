@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sync"
 
 	_ "github.com/lib/pq"
@@ -13,18 +14,30 @@ import (
 	"idcard/internal/util"
 )
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	sqliteMemory = ":memory:"
+)
+
 type DB interface {
 	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 	Exec(query string, args ...any) (sql.Result, error)
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 	Begin() (*sql.Tx, error)
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 	Close() error
+	// Driver reports which SQL dialect the connection speaks
+	Driver() string
 }
 
 type RealDB struct {
-	conn *sql.DB
+	conn   *sql.DB
+	driver string
 }
 
 var (
@@ -37,10 +50,18 @@ func (r *RealDB) Query(query string, args ...any) (*sql.Rows, error) {
 	return r.conn.Query(query, args...)
 }
 
+func (r *RealDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.conn.QueryContext(ctx, query, args...)
+}
+
 func (r *RealDB) QueryRow(query string, args ...any) *sql.Row {
 	return r.conn.QueryRow(query, args...)
 }
 
+func (r *RealDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.conn.QueryRowContext(ctx, query, args...)
+}
+
 func (r *RealDB) Exec(query string, args ...any) (sql.Result, error) {
 	return r.conn.Exec(query, args...)
 }
@@ -61,53 +82,108 @@ func (r *RealDB) Close() error {
 	return r.conn.Close()
 }
 
+func (r *RealDB) Driver() string {
+	return r.driver
+}
+
 // InitDB initializes the database connection once.
 func InitDB() (DB, error) {
 	once.Do(func() {
-		// Pool mode (direct, transaction, session)
-		poolMode := getEnv("DB_POOL_MODE", "transaction")
-
-		var host, port string
-		switch poolMode {
-		case "direct":
-			host = getEnv("POSTGRES_HOST", "localhost")
-			port = getEnv("POSTGRES_PORT", "5432") // direct port
-		case "transaction":
-			host = getEnv("POSTGRES_HOST", "localhost")
-			port = getEnv("POSTGRES_PORT", "6543") // tx pooler port
+		driver := getEnv("DB_DRIVER", DriverPostgres)
+
+		var (
+			conn *sql.DB
+			err  error
+		)
+		switch driver {
+		case DriverPostgres:
+			conn, err = openPostgres()
+		case DriverSQLite:
+			conn, err = openSQLite()
 		default:
-			log.Fatalf("unknown pool mode: %s", poolMode)
+			err = fmt.Errorf("unknown db driver: %s", driver)
 		}
-		connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
-			host,
-			port,
-			getEnv("POSTGRES_USER", "myuser"),
-			getEnv("POSTGRES_PASSWORD", ""),
-			getEnv("POSTGRES_DB", "mydatabase"),
-		)
-		conn, err := sql.Open("postgres", connStr)
 		if err != nil {
 			initErr = fmt.Errorf("[DB]Failed to connect to database: %v", err)
 			return
 		}
 
-		conn.SetMaxOpenConns(util.ParseInt(getEnv("MAX_OPEN_CONNS", "5")))
-		conn.SetMaxIdleConns(util.ParseInt(getEnv("MAX_IDLE_CONNS", "2")))
-		conn.SetConnMaxLifetime(util.ConMaxLifeTime)
-		conn.SetConnMaxIdleTime(util.ConIdleTime)
-
 		if err := conn.Ping(); err != nil {
-			log.Fatalf("[DB]Failed to ping database: %v via %s", err, poolMode)
+			log.Fatalf("[DB]Failed to ping database: %v via %s", err, driver)
 			initErr = fmt.Errorf("[DB]Failed to ping database: %v", err)
 			return
 		}
 
-		log.Println("[DB]Database connection established.")
-		dbInstance = &RealDB{conn: conn}
+		log.Printf("[DB]Database connection established (%s).", driver)
+		dbInstance = &RealDB{conn: conn, driver: driver}
 	})
 	return dbInstance, initErr
 }
 
+func openPostgres() (*sql.DB, error) {
+	// Pool mode (direct, transaction, session)
+	poolMode := getEnv("DB_POOL_MODE", "transaction")
+
+	var host, port string
+	switch poolMode {
+	case "direct":
+		host = getEnv("POSTGRES_HOST", "localhost")
+		port = getEnv("POSTGRES_PORT", "5432") // direct port
+	case "transaction":
+		host = getEnv("POSTGRES_HOST", "localhost")
+		port = getEnv("POSTGRES_PORT", "6543") // tx pooler port
+	default:
+		log.Fatalf("unknown pool mode: %s", poolMode)
+	}
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		host,
+		port,
+		getEnv("POSTGRES_USER", "myuser"),
+		getEnv("POSTGRES_PASSWORD", ""),
+		getEnv("POSTGRES_DB", "mydatabase"),
+		getEnv("POSTGRES_SSLMODE", "require"),
+	)
+	conn, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(util.ParseInt(getEnv("MAX_OPEN_CONNS", "5")))
+	conn.SetMaxIdleConns(util.ParseInt(getEnv("MAX_IDLE_CONNS", "2")))
+	conn.SetConnMaxLifetime(util.ConMaxLifeTime)
+	conn.SetConnMaxIdleTime(util.ConIdleTime)
+	return conn, nil
+}
+
+// openSQLite opens SQLITE_PATH, either a database file or ":memory:".
+func openSQLite() (*sql.DB, error) {
+	path := getEnv("SQLITE_PATH", "data/idcard.db")
+
+	dsn := "file::memory:?_foreign_keys=on&_txlock=immediate"
+	if path != sqliteMemory {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		dsn = fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path)
+	}
+
+	conn, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == sqliteMemory {
+		// Every connection to :memory: gets its own empty database, so keep exactly one
+		conn.SetMaxOpenConns(1)
+		conn.SetConnMaxLifetime(0)
+		conn.SetConnMaxIdleTime(0)
+		return conn, nil
+	}
+	conn.SetMaxOpenConns(util.ParseInt(getEnv("MAX_OPEN_CONNS", "5")))
+	conn.SetMaxIdleConns(util.ParseInt(getEnv("MAX_IDLE_CONNS", "2")))
+	return conn, nil
+}
+
 // CloseDB closes the database connection.
 func CloseDB() {
 	if dbInstance != nil {
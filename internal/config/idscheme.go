@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"idcard/internal/util"
+)
+
+const (
+	EnvIDNumberWidth   = "ID_NUMBER_WIDTH"
+	EnvIDSite          = "ID_SITE"
+	EnvIDYear          = "ID_YEAR"
+	EnvIDDisplayPrefix = "ID_DISPLAY_PREFIX"
+)
+
+type (
+	// IDScheme describes how card holder IDs are built: holder type prefix +
+	// optional site code + optional 2-digit year + zero-padded number.
+	// The number is padded to Width but grows past it instead of overflowing.
+	IDScheme struct {
+		Width         int
+		Site          string
+		Year          bool
+		DisplayPrefix string
+	}
+)
+
+func LoadIDScheme() (*IDScheme, error) {
+	s := &IDScheme{
+		Width:         util.ParseInt(getEnv(EnvIDNumberWidth, "3")),
+		Site:          strings.ToUpper(strings.TrimSpace(getEnv(EnvIDSite, ""))),
+		Year:          getEnv(EnvIDYear, "false") == "true",
+		DisplayPrefix: getEnv(EnvIDDisplayPrefix, "SIK-"),
+	}
+	if s.Width < 1 || s.Width > 9 {
+		return nil, fmt.Errorf("%s must be between 1 and 9", EnvIDNumberWidth)
+	}
+	for _, r := range s.Site {
+		if r < 'A' || r > 'Z' {
+			return nil, fmt.Errorf("%s must contain letters only", EnvIDSite)
+		}
+	}
+	return s, nil
+}
+
+// Scope is the part of a new ID before its number. Each scope has its own
+// sequence, so a year segment restarts numbering every year.
+func (s *IDScheme) Scope(prefix string, t time.Time) string {
+	scope := prefix + s.Site
+	if s.Year {
+		scope += t.Format("06")
+	}
+	return scope
+}
+
+// Format builds the ID numbered n within scope.
+func (s *IDScheme) Format(scope string, n int) string {
+	return fmt.Sprintf("%s%0*d", scope, s.Width, n)
+}
+
+// Number extracts the sequence number of id when it belongs to scope.
+func (s *IDScheme) Number(scope, id string) (int, bool) {
+	rest, ok := strings.CutPrefix(id, scope)
+	if !ok || rest == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil
+}
+
+// Display is the card number printed on cards and shown in the UI.
+func (s *IDScheme) Display(id string) string {
+	return s.DisplayPrefix + id
+}
+
+// LongestCardNo is a card number as long as the longest one issued with
+// prefix while numbers stay within Width digits, for sizing card layouts.
+func (s *IDScheme) LongestCardNo(prefix string) string {
+	scope := prefix + s.Site
+	if s.Year {
+		scope += "00"
+	}
+	return s.Display(s.Format(scope, 0))
+}
+
+// ParseCardNo is the holder ID of a card number as printed, scanned or
+// typed, with or without the display prefix and in any case.
+func (s *IDScheme) ParseCardNo(cardNo string) string {
+	id := strings.ToUpper(strings.TrimSpace(cardNo))
+	return strings.TrimPrefix(id, strings.ToUpper(s.DisplayPrefix))
+}
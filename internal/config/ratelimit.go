@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"idcard/internal/util"
+)
+
+const (
+	EnvVerifyRateLimit      = "VERIFY_RATE_LIMIT"
+	EnvVerifyClientIPHeader = "VERIFY_CLIENT_IP_HEADER"
+)
+
+type (
+	// RateLimit allows each client Requests per Window. Clients are told
+	// apart by ClientIPHeader when the app runs behind a proxy that sets it,
+	// such as Fly-Client-IP, and by the connection address otherwise.
+	RateLimit struct {
+		Requests       int
+		Window         time.Duration
+		ClientIPHeader string
+	}
+)
+
+// LoadVerifyRateLimit limits lookups on the public card verification page.
+func LoadVerifyRateLimit() (*RateLimit, error) {
+	l := &RateLimit{
+		Requests:       util.ParseInt(getEnv(EnvVerifyRateLimit, "20")),
+		Window:         time.Minute,
+		ClientIPHeader: getEnv(EnvVerifyClientIPHeader, ""),
+	}
+	if l.Requests < 1 {
+		return nil, fmt.Errorf("%s must be at least 1", EnvVerifyRateLimit)
+	}
+	return l, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"idcard/internal/util"
+)
+
+const (
+	EnvQCRatingWindow       = "QC_RATING_WINDOW"
+	EnvQCRatingDecay        = "QC_RATING_DECAY"
+	EnvQCGradeScores        = "QC_GRADE_SCORES"
+	EnvQCMoistureLimit      = "QC_MOISTURE_LIMIT"
+	EnvQCContaminationLimit = "QC_CONTAMINATION_LIMIT"
+	EnvQCPenaltyPerPoint    = "QC_PENALTY_PER_POINT"
+)
+
+type (
+	// RatingFormula turns QC inspections into a holder rating from 0 to 100.
+	// Each inspection scores its grade minus Penalty for every percentage
+	// point of moisture or contamination above the limits. The rating is the
+	// average score of the last Window inspections, each older one weighing
+	// Decay times the next.
+	RatingFormula struct {
+		Window             int
+		Decay              float64
+		Grades             map[string]int
+		MoistureLimit      float64
+		ContaminationLimit float64
+		Penalty            float64
+	}
+)
+
+func LoadRatingFormula() (*RatingFormula, error) {
+	f := &RatingFormula{
+		Window: util.ParseInt(getEnv(EnvQCRatingWindow, "10")),
+		Grades: map[string]int{},
+	}
+	if f.Window < 1 {
+		return nil, fmt.Errorf("%s must be at least 1", EnvQCRatingWindow)
+	}
+
+	for _, p := range []struct {
+		key, fallback string
+		dst           *float64
+	}{
+		{EnvQCRatingDecay, "0.85", &f.Decay},
+		{EnvQCMoistureLimit, "15", &f.MoistureLimit},
+		{EnvQCContaminationLimit, "2", &f.ContaminationLimit},
+		{EnvQCPenaltyPerPoint, "5", &f.Penalty},
+	} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(getEnv(p.key, p.fallback)), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number", p.key)
+		}
+		*p.dst = v
+	}
+	if f.Decay == 0 || f.Decay > 1 {
+		return nil, fmt.Errorf("%s must be above 0 and at most 1", EnvQCRatingDecay)
+	}
+
+	// Grades are listed as GRADE:SCORE pairs, e.g. A:100,B:80
+	for _, pair := range strings.Split(getEnv(EnvQCGradeScores, "A:100,B:80,C:60,D:40,E:20"), ",") {
+		grade, score, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		n, err := strconv.Atoi(strings.TrimSpace(score))
+		if !ok || grade == "" || err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("%s: invalid grade %q, want GRADE:SCORE with a score from 0 to 100", EnvQCGradeScores, pair)
+		}
+		f.Grades[strings.ToUpper(strings.TrimSpace(grade))] = n
+	}
+	return f, nil
+}
+
+// GradeNames lists the configured grades, best score first.
+func (f *RatingFormula) GradeNames() []string {
+	names := make([]string, 0, len(f.Grades))
+	for g := range f.Grades {
+		names = append(names, g)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if f.Grades[names[i]] != f.Grades[names[j]] {
+			return f.Grades[names[i]] > f.Grades[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
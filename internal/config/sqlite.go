@@ -0,0 +1,60 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is go-sqlite3 with Postgres-style placeholders.
+//
+// SQLite reads "$1" as a named parameter and numbers parameters by first
+// appearance, so "WHERE b=$2 AND a=$1" would bind the arguments swapped.
+// Rewriting to "?NNN" keeps the repository SQL identical on both databases.
+const sqliteDriverName = "sqlite3_pg"
+
+var pgPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{base: &sqlite3.SQLiteDriver{}})
+}
+
+func rebindSQLite(query string) string {
+	return pgPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+type (
+	sqliteDriver struct {
+		base *sqlite3.SQLiteDriver
+	}
+	sqliteConn struct {
+		*sqlite3.SQLiteConn
+	}
+)
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.base.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{SQLiteConn: c.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.SQLiteConn.Prepare(rebindSQLite(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.SQLiteConn.PrepareContext(ctx, rebindSQLite(query))
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, rebindSQLite(query), args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, rebindSQLite(query), args)
+}
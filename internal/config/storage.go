@@ -3,12 +3,15 @@ package config
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 const (
@@ -16,11 +19,21 @@ const (
 	EnvCFSecretKey = "CLOUDFLARE_SECRET_KEY"
 	EnvCFEndpoint  = "CLOUDFLARE_ENDPOINT"
 	EnvCFBucket    = "CLOUDFLARE_BUCKET_NAME"
-	EnvBucketUrl   = "BUCKET_URL"
+
+	EnvStorageDriver     = "STORAGE_DRIVER"
+	EnvLocalStoragePath  = "LOCAL_STORAGE_PATH"
+	EnvStorageSigningKey = "STORAGE_SIGNING_KEY"
+
+	StorageDriverS3    = "s3"
+	StorageDriverLocal = "local"
+
+	// LocalStorageRoute is where the local backend serves stored objects
+	LocalStorageRoute = "/storage/"
 )
 
 var (
-	BucketURL string
+	// ErrObjectNotFound is returned by Client.Download when the key does not exist
+	ErrObjectNotFound = errors.New("storage object not found")
 )
 
 type (
@@ -28,12 +41,18 @@ type (
 		Upload(ctx context.Context, key, mime string, body io.Reader) error
 		Download(ctx context.Context, key string) ([]byte, error)
 		Delete(ctx context.Context, key string) error
+		// PresignGet returns a URL granting read access to key for ttl
+		PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
 	}
 	storageClient struct {
-		client *s3.Client
-		bucket string
+		client  *s3.Client
+		presign *s3.PresignClient
+		bucket  string
 	}
 	StorageConfig struct {
+		Driver              string
+		LocalPath           string
+		SigningKey          string
 		CloudflareAccessKey string
 		CloudflareSecretKey string
 		CloudflareEndpoint  string
@@ -43,23 +62,51 @@ type (
 
 func NewStorageClient(client *s3.Client, bucket string) *storageClient {
 	return &storageClient{
-		client: client,
-		bucket: bucket,
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
 	}
 }
 
 func LoadStorageConfig() (*StorageConfig, error) {
-	BucketURL = getEnv(EnvBucketUrl, "")
 	c := &StorageConfig{
-		CloudflareAccessKey: getEnv(EnvCFAccessKey, ""),
-		CloudflareSecretKey: getEnv(EnvCFSecretKey, ""),
-		CloudflareEndpoint:  "https://" + getEnv(EnvCFEndpoint, ""),
-		CloudflareBucket:    getEnv(EnvCFBucket, ""),
+		Driver:    getEnv(EnvStorageDriver, StorageDriverS3),
+		LocalPath: getEnv(EnvLocalStoragePath, "data/storage"),
+	}
+
+	switch c.Driver {
+	case StorageDriverLocal:
+		c.SigningKey = getEnv(EnvStorageSigningKey, "")
+		if c.SigningKey == "" {
+			return nil, errors.New("missing required " + EnvStorageSigningKey + " for local storage")
+		}
+		return c, nil
+	case StorageDriverS3:
+		c.CloudflareAccessKey = getEnv(EnvCFAccessKey, "")
+		c.CloudflareSecretKey = getEnv(EnvCFSecretKey, "")
+		c.CloudflareEndpoint = getEnv(EnvCFEndpoint, "")
+		c.CloudflareBucket = getEnv(EnvCFBucket, "")
+		if c.CloudflareAccessKey == "" || c.CloudflareSecretKey == "" || c.CloudflareEndpoint == "" || c.CloudflareBucket == "" {
+			return nil, errors.New("missing required cloudflare storage env vars")
+		}
+		c.CloudflareEndpoint = "https://" + c.CloudflareEndpoint
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", c.Driver)
 	}
-	if c.CloudflareAccessKey == "" || c.CloudflareSecretKey == "" || c.CloudflareEndpoint == "" || c.CloudflareBucket == "" {
-		return nil, errors.New("missing required cloudflare storage env vars")
+}
+
+// NewClient builds the storage backend selected by cfg.Driver.
+func NewClient(ctx context.Context, cfg *StorageConfig) (Client, error) {
+	if cfg.Driver == StorageDriverLocal {
+		return NewLocalStorageClient(cfg.LocalPath, []byte(cfg.SigningKey))
+	}
+
+	s3Client, err := InitStorageClient(ctx, cfg.CloudflareAccessKey, cfg.CloudflareSecretKey, cfg.CloudflareEndpoint)
+	if err != nil {
+		return nil, err
 	}
-	return c, nil
+	return NewStorageClient(s3Client, cfg.CloudflareBucket), nil
 }
 
 func InitStorageClient(ctx context.Context, accessKey, secretKey, endpoint string) (*s3.Client, error) {
@@ -94,6 +141,10 @@ func (s *storageClient) Download(ctx context.Context, key string) ([]byte, error
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
+	var noKey *types.NoSuchKey
+	if errors.As(err, &noKey) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -108,3 +159,14 @@ func (s *storageClient) Delete(ctx context.Context, key string) error {
 	})
 	return err
 }
+
+func (s *storageClient) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
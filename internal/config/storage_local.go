@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	localStorageClient struct {
+		root   string
+		secret []byte
+	}
+)
+
+func NewLocalStorageClient(root string, secret []byte) (*localStorageClient, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create local storage root: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("local storage requires a signing secret")
+	}
+	return &localStorageClient{root: root, secret: secret}, nil
+}
+
+func (s *localStorageClient) Upload(ctx context.Context, key, mime string, body io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, &ctxReader{ctx: ctx, r: body}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localStorageClient) Download(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return data, err
+}
+
+func (s *localStorageClient) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	// Deleting a missing object is not an error, same as S3
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PresignGet returns an app-relative URL for key that Handler accepts until ttl passes.
+func (s *localStorageClient) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("sig", s.sign(key, expires))
+	return LocalStorageRoute + key + "?" + q.Encode(), nil
+}
+
+// Handler serves stored objects read-only to requests carrying a valid,
+// unexpired signature from PresignGet. Directory listings are never served.
+func (s *localStorageClient) Handler() http.Handler {
+	fs := http.FileServer(http.Dir(s.root))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		expires := r.URL.Query().Get("expires")
+		exp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > exp {
+			http.Error(w, "link expired", http.StatusForbidden)
+			return
+		}
+		sig := r.URL.Query().Get("sig")
+		if !hmac.Equal([]byte(sig), []byte(s.sign(key, expires))) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		fs.ServeHTTP(w, r)
+	})
+}
+
+func (s *localStorageClient) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps an object key to a file below root, rejecting keys that escape it.
+func (s *localStorageClient) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// ctxReader stops a copy as soon as ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"idcard/internal/util"
+)
+
+const (
+	EnvCardValidityMonths    = "CARD_VALIDITY_MONTHS"
+	EnvCardExpiryWarningDays = "CARD_EXPIRY_WARNING_DAYS"
+)
+
+type (
+	// CardValidity is how long a printed card stays valid and how early
+	// holders are flagged before it runs out.
+	CardValidity struct {
+		Months      int
+		WarningDays int
+	}
+)
+
+func LoadCardValidity() (*CardValidity, error) {
+	v := &CardValidity{
+		Months:      util.ParseInt(getEnv(EnvCardValidityMonths, "12")),
+		WarningDays: util.ParseInt(getEnv(EnvCardExpiryWarningDays, "30")),
+	}
+	if v.Months < 1 || v.Months > 120 {
+		return nil, fmt.Errorf("%s must be between 1 and 120", EnvCardValidityMonths)
+	}
+	if v.WarningDays < 0 {
+		return nil, fmt.Errorf("%s must not be negative", EnvCardExpiryWarningDays)
+	}
+	return v, nil
+}
+
+// Until is the last day a card issued at t is valid.
+func (v *CardValidity) Until(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m+time.Month(v.Months), d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/service"
+	"idcard/internal/util"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DeliveryHandler struct {
+	Service service.DeliveryService
+	IDs     *config.IDScheme
+	Grades  []string
+}
+
+func NewDeliveryHandler(svc service.DeliveryService, ids *config.IDScheme, grades []string) *DeliveryHandler {
+	loadTemplates()
+	return &DeliveryHandler{Service: svc, IDs: ids, Grades: grades}
+}
+
+// PageHandler renders the weighbridge page: the entry form, the delivery log
+// and its totals for the filter in the query.
+func (h *DeliveryHandler) PageHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, err := parseDeliveryFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.Service.GetDeliveries(r.Context(), f, q.Get("period"))
+	if errors.Is(err, service.ErrInvalidPeriod) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "deliveries.html", map[string]any{
+		"Report":   report,
+		"IDPrefix": h.IDs.DisplayPrefix,
+		"Grades":   h.Grades,
+		"Query":    q,
+		"Export":   "/deliveries/export?" + q.Encode(),
+		"Today":    time.Now().Format(dateLayout),
+	})
+}
+
+// ListHandler returns the deliveries and totals matching uid (or a card
+// number), material, from and to (YYYY-MM-DD), totalled per period (day or
+// month).
+func (h *DeliveryHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	f, err := parseDeliveryFilter(q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
+		return
+	}
+	report, err := h.Service.GetDeliveries(r.Context(), f, q.Get("period"))
+	if err != nil {
+		log.Println(err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidPeriod) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error listing deliveries: %s", err.Error())})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Data": report})
+}
+
+// AddHandler records a delivery: card (scanned or typed), delivered_on
+// (YYYY-MM-DD), vehicle, material, gross_kg, tare_kg and notes. When grade
+// is given the load's QC inspection is recorded with it, from moisture,
+// contamination and inspector.
+func (h *DeliveryHandler) AddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cardNo := strings.TrimSpace(r.FormValue("card"))
+	d := &model.Delivery{
+		Vehicle:  r.FormValue("vehicle"),
+		Material: r.FormValue("material"),
+		Notes:    strings.TrimSpace(r.FormValue("notes")),
+	}
+	if v := r.FormValue("delivered_on"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid delivered_on: %s", v)})
+			return
+		}
+		d.DeliveredOn = t
+	}
+
+	for _, p := range []struct {
+		key string
+		dst *float64
+	}{{"gross_kg", &d.GrossKg}, {"tare_kg", &d.TareKg}} {
+		v := strings.TrimSpace(r.FormValue(p.key))
+		f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid %s: %s", p.key, v)})
+			return
+		}
+		*p.dst = f
+	}
+
+	var qc *model.Inspection
+	if grade := strings.TrimSpace(r.FormValue("grade")); grade != "" {
+		qc = &model.Inspection{
+			Grade:     grade,
+			Inspector: strings.TrimSpace(r.FormValue("inspector")),
+			Notes:     d.Notes,
+		}
+		if qc.Inspector == "" {
+			qc.Inspector = strings.TrimSpace(r.FormValue("operator"))
+		}
+		for _, p := range []struct {
+			key string
+			dst **float64
+		}{{"moisture", &qc.Moisture}, {"contamination", &qc.Contamination}} {
+			if v := strings.TrimSpace(r.FormValue(p.key)); v != "" {
+				f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+				if err != nil {
+					json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid %s: %s", p.key, v)})
+					return
+				}
+				*p.dst = &f
+			}
+		}
+	}
+
+	if err := h.Service.AddDelivery(withActor(r, model.SourceForm), cardNo, d, qc); err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not add delivery for %s: %s", cardNo, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Data": d})
+}
+
+// ExportHandler downloads the deliveries matching the filter, with totals,
+// as XLSX.
+func (h *DeliveryHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, err := parseDeliveryFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.Service.ExportDeliveries(r.Context(), f, q.Get("period"), &buf); err != nil {
+		log.Println(err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidPeriod) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	name := "setoran"
+	for _, v := range []string{q.Get("from"), q.Get("to")} {
+		if v != "" {
+			name += "-" + v
+		}
+	}
+	if err := util.ServeDownloadables(w, r, bytes.NewReader(buf.Bytes()), name+".xlsx"); err != nil {
+		log.Println(err)
+	}
+}
+
+// parseDeliveryFilter reads the holder (uid, or a card number in card),
+// material, from and to parameters.
+func parseDeliveryFilter(q url.Values) (model.DeliveryFilter, error) {
+	f := model.DeliveryFilter{
+		UserID:   strings.TrimSpace(q.Get("uid")),
+		Material: q.Get("material"),
+	}
+	if f.UserID == "" {
+		f.UserID = strings.TrimSpace(q.Get("card"))
+	}
+	for _, p := range []struct {
+		key string
+		dst **time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := q.Get(p.key); v != "" {
+			t, err := time.Parse(dateLayout, v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s: %s", p.key, v)
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
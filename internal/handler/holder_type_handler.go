@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"idcard/internal/model"
+	"idcard/internal/service"
+	"log"
+	"net/http"
+)
+
+type (
+	HolderTypeHandler struct {
+		Service service.HolderTypeService
+	}
+)
+
+func NewHolderTypeHandler(svc service.HolderTypeService) *HolderTypeHandler {
+	loadTemplates()
+	return &HolderTypeHandler{Service: svc}
+}
+
+// PageHandler renders the holder type admin page.
+func (h *HolderTypeHandler) PageHandler(w http.ResponseWriter, r *http.Request) {
+	types, err := h.Service.ListHolderTypes(r.Context())
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "holder_types.html", map[string]any{"Types": types})
+}
+
+func (h *HolderTypeHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	types, err := h.Service.ListHolderTypes(r.Context())
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error listing holder types: %s", err.Error())})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Data": types})
+}
+
+// SaveHandler creates a holder type, or updates it when mode=update.
+func (h *HolderTypeHandler) SaveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	t := &model.HolderType{
+		Code:         r.FormValue("code"),
+		Label:        r.FormValue("label"),
+		IDPrefix:     r.FormValue("id_prefix"),
+		CardTemplate: r.FormValue("card_template"),
+		PDFForm:      r.FormValue("pdf_form"),
+		BackTemplate: r.FormValue("back_template"),
+	}
+
+	var err error
+	if r.FormValue("mode") == "update" {
+		err = h.Service.UpdateHolderType(r.Context(), t)
+	} else {
+		err = h.Service.CreateHolderType(r.Context(), t)
+	}
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not save holder type %s: %s", t.Code, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Data": t.Code})
+}
+
+func (h *HolderTypeHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	code := r.FormValue("code")
+
+	if err := h.Service.DeleteHolderType(r.Context(), code); err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not delete holder type %s: %s", code, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Data": code})
+}
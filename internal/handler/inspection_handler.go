@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/service"
+	"idcard/internal/util"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	InspectionHandler struct {
+		Service     service.InspectionService
+		UserService service.UserService
+		IDs         *config.IDScheme
+		Grades      []string
+	}
+
+	// ratingChart is the rating trend drawn as an SVG polyline.
+	ratingChart struct {
+		Width, Height int
+		Points        string
+		Dots          []chartDot
+	}
+	chartDot struct {
+		X, Y  int
+		Label string
+	}
+)
+
+const (
+	chartWidth  = 600
+	chartHeight = 200
+	chartMargin = 10
+)
+
+func NewInspectionHandler(svc service.InspectionService, users service.UserService, ids *config.IDScheme, grades []string) *InspectionHandler {
+	loadTemplates()
+	return &InspectionHandler{Service: svc, UserService: users, IDs: ids, Grades: grades}
+}
+
+// PageHandler renders the holder page with its QC inspections and rating trend.
+func (h *InspectionHandler) PageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("uid")
+	ctx := r.Context()
+
+	user, err := h.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, fmt.Sprintf("user %s: %s", userID, err.Error()), http.StatusNotFound)
+		return
+	}
+	history, err := h.Service.GetInspections(ctx, userID)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "holder.html", map[string]any{
+		"User":     user,
+		"IDPrefix": h.IDs.DisplayPrefix,
+		"History":  history,
+		"Chart":    newRatingChart(history.Trend),
+		"Grades":   h.Grades,
+		"Today":    time.Now().Format(dateLayout),
+	})
+}
+
+// ListHandler returns the inspections and rating trend of holder uid.
+func (h *InspectionHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.URL.Query().Get("uid")
+
+	history, err := h.Service.GetInspections(r.Context(), userID)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error listing inspections of %s: %s", userID, err.Error())})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Data": history})
+}
+
+// AddHandler records an inspection: uid, inspected_on (YYYY-MM-DD), grade,
+// moisture and contamination (percent, optional), inspector and notes.
+func (h *InspectionHandler) AddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	in := &model.Inspection{
+		UserID:    r.FormValue("uid"),
+		Grade:     r.FormValue("grade"),
+		Inspector: strings.TrimSpace(r.FormValue("inspector")),
+		Notes:     strings.TrimSpace(r.FormValue("notes")),
+	}
+	if v := r.FormValue("inspected_on"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid inspected_on: %s", v)})
+			return
+		}
+		in.InspectedOn = t
+	}
+	for _, p := range []struct {
+		key string
+		dst **float64
+	}{{"moisture", &in.Moisture}, {"contamination", &in.Contamination}} {
+		if v := strings.TrimSpace(r.FormValue(p.key)); v != "" {
+			f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+			if err != nil {
+				json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid %s: %s", p.key, v)})
+				return
+			}
+			*p.dst = &f
+		}
+	}
+
+	history, err := h.Service.AddInspection(withActor(r, model.SourceForm), in)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not add inspection for %s: %s", in.UserID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Data": history})
+}
+
+// newRatingChart scales the trend to the chart, ratings 0 to 100 bottom to top.
+func newRatingChart(trend []model.RatingPoint) ratingChart {
+	c := ratingChart{Width: chartWidth, Height: chartHeight}
+	step := 0
+	if len(trend) > 1 {
+		step = (chartWidth - 2*chartMargin) / (len(trend) - 1)
+	}
+	points := make([]string, 0, len(trend))
+	for i, p := range trend {
+		x := chartMargin + i*step
+		y := chartHeight - chartMargin - p.Rating*(chartHeight-2*chartMargin)/100
+		points = append(points, fmt.Sprintf("%d,%d", x, y))
+		c.Dots = append(c.Dots, chartDot{X: x, Y: y, Label: fmt.Sprintf("%s: %d (skor %d)", p.Date.Format(util.CardDateLayout), p.Rating, p.Score)})
+	}
+	c.Points = strings.Join(points, " ")
+	return c
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"fmt"
+	"idcard/internal/model"
+	"idcard/internal/util"
+)
+
+// stateWarning tells gate staff why holder u must be refused entry. It is
+// empty for holders in good standing.
+func stateWarning(u *model.User) string {
+	var warning string
+	switch u.State {
+	case model.StateSuspended:
+		warning = "DITANGGUHKAN"
+		if u.StateUntil != nil {
+			warning += " s/d " + u.StateUntil.Format(util.CardDateLayout)
+		}
+	case model.StateBlacklisted:
+		warning = "DIBLOKIR"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%s: %s, masuk tidak diizinkan", warning, u.StateReason)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"idcard/internal/service"
+	"idcard/internal/util"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// parseUserFilter reads list filters from query parameters:
+// q, status, inactive, min_rating, max_rating, created_from, created_to
+// (YYYY-MM-DD, inclusive), province, regency, gender (L|P), min_age, max_age,
+// validity (expired|expiring), state (active|suspended|blacklisted), sort, order (asc|desc), cursor and limit.
+func parseUserFilter(q url.Values) (model.UserFilter, error) {
+	f := model.UserFilter{
+		Query:    strings.TrimSpace(q.Get("q")),
+		Status:   q.Get("status"),
+		Inactive: q.Get("inactive") == "true",
+		Province: q.Get("province"),
+		Regency:  q.Get("regency"),
+		Gender:   q.Get("gender"),
+		Validity: q.Get("validity"),
+		State:    q.Get("state"),
+		Sort:     q.Get("sort"),
+		Cursor:   q.Get("cursor"),
+	}
+	if f.Gender != "" && f.Gender != util.GenderMale && f.Gender != util.GenderFemale {
+		return f, fmt.Errorf("invalid gender: %s", f.Gender)
+	}
+
+	switch f.State {
+	case "", model.StateActive, model.StateSuspended, model.StateBlacklisted:
+	default:
+		return f, fmt.Errorf("invalid state: %s", f.State)
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+		f.Desc = true
+	case "asc":
+	default:
+		return f, fmt.Errorf("invalid order: %s", q.Get("order"))
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return f, fmt.Errorf("invalid limit: %s", v)
+		}
+		f.Limit = n
+	}
+
+	for _, p := range []struct {
+		key string
+		dst **int
+	}{{"min_rating", &f.MinRating}, {"max_rating", &f.MaxRating}, {"min_age", &f.MinAge}, {"max_age", &f.MaxAge}} {
+		if v := q.Get(p.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s: %s", p.key, v)
+			}
+			*p.dst = &n
+		}
+	}
+
+	if v := q.Get("created_from"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return f, fmt.Errorf("invalid created_from: %s", v)
+		}
+		f.CreatedFrom = &t
+	}
+	if v := q.Get("created_to"); v != "" {
+		t, err := time.Parse(dateLayout, v)
+		if err != nil {
+			return f, fmt.Errorf("invalid created_to: %s", v)
+		}
+		// the whole end day is included
+		t = t.AddDate(0, 0, 1)
+		f.CreatedTo = &t
+	}
+	return f, nil
+}
+
+func isFilterErr(err error) bool {
+	return errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidSort) ||
+		errors.Is(err, service.ErrInvalidValidity)
+}
+
+// nextPageURL links the index page with the current filters and the next
+// cursor. It is empty on the last page.
+func nextPageURL(q url.Values, cursor string) template.URL {
+	if cursor == "" {
+		return ""
+	}
+	next := url.Values{}
+	for k, v := range q {
+		if k != "success" && k != "cursor" {
+			next[k] = v
+		}
+	}
+	next.Set("cursor", cursor)
+	return template.URL("/?" + next.Encode())
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"idcard/internal/config"
@@ -11,56 +14,178 @@ import (
 	"idcard/internal/util"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type (
 	UserHandler struct {
 		UserService service.UserService
+		HolderTypes service.HolderTypeService
+		IDs         *config.IDScheme
 	}
 )
 
 var (
-	tmpl *template.Template
+	tmpl     *template.Template
+	tmplOnce sync.Once
 )
 
-func NewUserHandler(svc service.UserService) *UserHandler {
-	tmpl = template.Must(template.ParseGlob("templates/*.html"))
-	return &UserHandler{UserService: svc}
+// loadTemplates parses templates/*.html once for every handler.
+func loadTemplates() {
+	tmplOnce.Do(func() {
+		tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+			"publicURL":  util.PublicURL,
+			"localPhone": util.LocalPhone,
+		}).ParseGlob("templates/*.html"))
+	})
+}
+
+func NewUserHandler(svc service.UserService, types service.HolderTypeService, ids *config.IDScheme) *UserHandler {
+	loadTemplates()
+	return &UserHandler{UserService: svc, HolderTypes: types, IDs: ids}
 }
 
 func (h *UserHandler) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	qParam := r.URL.Query()
-	limitQ := qParam.Get("limit")
 	lastID := qParam.Get("success")
 
-	limit, err := strconv.Atoi(limitQ)
+	filter, err := parseUserFilter(qParam)
 	if err != nil {
-		limit = 12 // default 12
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 	ctx := r.Context()
-	users, err := h.UserService.GetUserList(ctx, uint8(limit))
+	var page *model.UserPage
+	if qParam.Get("fuzzy") == "true" && filter.Query != "" {
+		page, err = h.searchPage(ctx, filter)
+	} else {
+		page, err = h.UserService.GetUserList(ctx, filter)
+	}
+	if isFilterErr(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Println(err)
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	newID, err := h.UserService.GenerateUserID(ctx, "S")
+	types, err := h.HolderTypes.ListHolderTypes(ctx)
 	if err != nil {
 		log.Println(err)
-		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error generating new ID for: %s", err.Error())})
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if len(types) == 0 {
+		http.Error(w, "no holder types configured, add one at /holder-types", 500)
 		return
 	}
+	// The form reserves an ID through /get-id once it is used, so merely
+	// viewing the list does not use one up
 	tmpl.ExecuteTemplate(w, "index.html", map[string]any{
-		"LastID": lastID,
-		"Action": "/create",
-		"Method": "POST",
-		"UserID": newID,
-		"Users":  users,
+		"LastID":      lastID,
+		"Action":      "/create",
+		"Method":      "POST",
+		"IDPrefix":    h.IDs.DisplayPrefix,
+		"Inactive":    filter.Inactive,
+		"Filter":      qParam,
+		"Provinces":   util.Provinces,
+		"HolderTypes": types,
+		"Users":       page.Users,
+		"NextPage":    nextPageURL(qParam, page.NextCursor),
 	})
 }
 
+// ListUsersHandler serves the searchable card holder list as JSON.
+func (h *UserHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
+		return
+	}
+	page, err := h.UserService.GetUserList(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		status := http.StatusInternalServerError
+		if isFilterErr(err) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error listing users: %s", err.Error())})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"Data": page.Users, "NextCursor": page.NextCursor})
+}
+
+// searchPage lists one page of the fuzzy name matches of filter.Query
+// among the holders matching the rest of filter.
+func (h *UserHandler) searchPage(ctx context.Context, filter model.UserFilter) (*model.UserPage, error) {
+	matches, err := h.UserService.SearchUsersByName(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &model.UserPage{Users: make([]model.User, 0, len(matches.Matches)), NextCursor: matches.NextCursor}
+	for _, m := range matches.Matches {
+		page.Users = append(page.Users, m.User)
+	}
+	return page, nil
+}
+
+// SearchUsersHandler ranks holders by fuzzy name match for ?name=. The
+// filters of ListUsersHandler narrow the candidates; sort and order do not
+// apply as results are ranked.
+func (h *UserHandler) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	filter, err := parseUserFilter(q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
+		return
+	}
+	filter.Query = strings.TrimSpace(q.Get("name"))
+
+	page, err := h.UserService.SearchUsersByName(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		status := http.StatusInternalServerError
+		if isFilterErr(err) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error searching users: %s", err.Error())})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"Data": page.Matches, "NextCursor": page.NextCursor})
+}
+
+// DemographicsHandler reports active holders by region, gender and age.
+func (h *UserHandler) DemographicsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	report, err := h.UserService.GetDemographics(r.Context())
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error building report: %s", err.Error())})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"Data": report})
+}
+
 func (h *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	nik := r.URL.Query().Get("nik")
@@ -71,20 +196,37 @@ func (h *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error getting user of NIK: %s | %s", nik, err.Error())})
 		return
 	}
+	links, err := h.UserService.GetDocumentURLs(r.Context(), user.ID)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error signing documents of: %s | %s", user.ID, err.Error())})
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]any{"Data": user})
+	json.NewEncoder(w).Encode(map[string]any{"Data": user, "Links": links, "Warning": stateWarning(user)})
 }
 
+// GetIdHandler reserves the next ID of a holder type. It only answers POST
+// so prefetches, crawlers and retried GETs do not use up IDs.
 func (h *UserHandler) GetIdHandler(w http.ResponseWriter, r *http.Request) {
-	status := "s"
-	statusQ := r.URL.Query().Get("status")
-	if statusQ != "" {
-		status = statusQ
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := r.FormValue("status")
+	if status == "" {
+		types, err := h.HolderTypes.ListHolderTypes(r.Context())
+		if err != nil || len(types) == 0 {
+			log.Println(err)
+			json.NewEncoder(w).Encode(map[string]string{"Error": "no holder types configured"})
+			return
+		}
+		status = types[0].Code
 	}
 
-	userID, err := h.UserService.GenerateUserID(r.Context(), status)
+	reserved, err := h.UserService.ReserveUserID(r.Context(), status)
 	if err != nil {
 		log.Println(err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error generating new ID for: %s | %s", status, err.Error())})
@@ -92,7 +234,11 @@ func (h *UserHandler) GetIdHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"Data": userID})
+	json.NewEncoder(w).Encode(map[string]any{
+		"Data":        reserved.ID,
+		"Reservation": reserved.Token,
+		"ExpiresAt":   reserved.ExpiresAt,
+	})
 }
 
 func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -100,14 +246,9 @@ func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	ctx := r.Context()
+	ctx := withActor(r, model.SourceForm)
 	status := r.FormValue("status")
-	userId, err := h.UserService.GenerateUserID(ctx, status)
-	if err != nil {
-		log.Println(err)
-		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error generating new ID for: %s | %s", status, err.Error())})
-		return
-	}
+	userId := r.FormValue("userIdInput")
 
 	rating := "0"
 	if r.FormValue("rating") != "" {
@@ -115,7 +256,6 @@ func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	formData := map[string]string{
-		"id":        userId,
 		"nik":       r.FormValue("nik"),
 		"name":      r.FormValue("name"),
 		"status":    status,
@@ -143,7 +283,7 @@ func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.UserService.CreateUserAction(ctx, &model.User{
+	user := &model.User{
 		ID:      userId,
 		NIK:     formData["nik"],
 		Name:    formData["name"],
@@ -152,8 +292,9 @@ func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 		Address: formData["address"],
 		Rating:  util.ParseInt(rating),
 		Notes:   formData["notes"],
-		Photo:   fmt.Sprintf("%s/%s%s.png", config.BucketURL, util.PathToUploads, userId),
-	}, imgByte)
+		Photo:   fmt.Sprintf("%s%s.png", util.PathToUploads, userId),
+	}
+	err = h.UserService.CreateUserAction(ctx, user, imgByte, r.FormValue("reservation"))
 	if err != nil {
 		log.Println(err)
 		json.NewEncoder(w).Encode(map[string]any{
@@ -162,7 +303,7 @@ func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	http.Redirect(w, r, "/?success="+formData["id"], http.StatusSeeOther)
+	http.Redirect(w, r, "/?success="+user.ID, http.StatusSeeOther)
 }
 
 func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -171,7 +312,7 @@ func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ctx := r.Context()
+	ctx := withActor(r, model.SourceForm)
 	rating := "0"
 	notes := " "
 	if r.FormValue("rating") != "" {
@@ -210,7 +351,7 @@ func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	imgPath := fmt.Sprintf("%s/%s%s.png", config.BucketURL, util.PathToUploads, formData["id"])
+	imgPath := fmt.Sprintf("%s%s.png", util.PathToUploads, formData["id"])
 
 	err = h.UserService.UpdateUserAction(ctx, &model.User{
 		ID:      formData["id"],
@@ -234,27 +375,227 @@ func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/?success="+formData["id"], http.StatusSeeOther)
 }
 
+// DeleteUserHandler soft deletes a holder with a reason, or removes it with
+// its stored files when hard=true.
+func (h *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := withActor(r, model.SourceForm)
+	userID := r.FormValue("uid")
+
+	var err error
+	if r.FormValue("hard") == "true" {
+		err = h.UserService.DeleteUser(ctx, userID)
+	} else {
+		err = h.UserService.DeactivateUser(ctx, userID, r.FormValue("reason"))
+	}
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not delete user %s: %s", userID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Data": userID})
+}
+
+func (h *UserHandler) RestoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.FormValue("uid")
+
+	if err := h.UserService.RestoreUser(withActor(r, model.SourceForm), userID); err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not restore user %s: %s", userID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Data": userID})
+}
+
+// DuplicatesHandler lists holders that may be the same person as the stored
+// holder ?uid=, or as the unsaved form values nik, name, phone and address.
+func (h *UserHandler) DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	u := &model.User{ID: q.Get("id"), NIK: q.Get("nik"), Name: q.Get("name"), Phone: q.Get("phone"), Address: q.Get("address")}
+	if uid := q.Get("uid"); uid != "" {
+		stored, err := h.UserService.GetUserByID(r.Context(), uid)
+		if err != nil {
+			log.Println(err)
+			json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error getting user %s: %s", uid, err.Error())})
+			return
+		}
+		u = stored
+	}
+
+	candidates, err := h.UserService.FindDuplicates(r.Context(), u)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error checking duplicates: %s", err.Error())})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"Data": candidates})
+}
+
+// MergeUserHandler keeps holder keep and retires holder retire as its duplicate.
+func (h *UserHandler) MergeUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	keepID, retireID := r.FormValue("keep"), r.FormValue("retire")
+
+	if err := h.UserService.MergeUsers(withActor(r, model.SourceForm), keepID, retireID); err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not merge %s into %s: %s", retireID, keepID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"Data": keepID})
+}
+
+// RenewUserHandler extends the card validity of holder uid and renders its
+// card and form again.
+func (h *UserHandler) RenewUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.FormValue("uid")
+
+	u, err := h.UserService.RenewUser(withActor(r, model.SourceForm), userID)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not renew user %s: %s", userID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Data": u})
+}
+
+// LookupHandler tells the weighbridge who holds a card number read by a
+// barcode scanner, such as SIK-S001, and whether they may deliver.
+func (h *UserHandler) LookupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	code := r.URL.Query().Get("code")
+
+	lookup, err := h.UserService.LookupCard(r.Context(), code)
+	if err != nil {
+		log.Println(err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("error looking up card %s: %s", code, err.Error())})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Data": lookup})
+}
+
+// ScanHandler verifies the QR code of a card, given as code, and returns
+// the verdict with the holder's current state and photo.
+func (h *UserHandler) ScanHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	code := r.FormValue("code")
+
+	scan, err := h.UserService.ScanCard(r.Context(), code)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("could not verify card: %s", err.Error())})
+		return
+	}
+	var warning string
+	if scan.User != nil {
+		warning = stateWarning(scan.User)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"Data": scan, "Warning": warning})
+}
+
+// ChangeStateHandler suspends, blacklists or reinstates holder uid. Dates
+// effective_from and effective_until are YYYY-MM-DD; the end date only
+// applies to suspensions.
+func (h *UserHandler) ChangeStateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.FormValue("uid")
+	change := model.StateChange{State: r.FormValue("state"), Reason: r.FormValue("reason")}
+	for _, p := range []struct {
+		key string
+		dst **time.Time
+	}{{"effective_from", &change.EffectiveFrom}, {"effective_until", &change.EffectiveUntil}} {
+		if v := r.FormValue(p.key); v != "" {
+			t, err := time.Parse(dateLayout, v)
+			if err != nil {
+				json.NewEncoder(w).Encode(map[string]string{"Error": fmt.Sprintf("invalid %s: %s", p.key, v)})
+				return
+			}
+			*p.dst = &t
+		}
+	}
+
+	u, err := h.UserService.ChangeUserState(withActor(r, model.SourceForm), userID, change)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not change state of %s: %s", userID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Data": u})
+}
+
 func (h *UserHandler) DownloadRedirecthandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	queryParams := r.URL.Query()
 	fileType := queryParams.Get("type")
 	userID := queryParams.Get("uid")
-	var filePath, fileName string
 
 	switch fileType {
-	case "card":
-		fileName = fmt.Sprintf("%s.png", userID)
-		filePath = fmt.Sprintf("%s%s.png", util.PathToCard, userID)
-	case "form":
-		fileName = fmt.Sprintf("%s.pdf", userID)
-		filePath = fmt.Sprintf("%s%s.pdf", util.PathToContract, userID)
+	case service.DocCard, service.DocCardBack, service.DocCardPrint, service.DocForm:
 	default:
 		json.NewEncoder(w).Encode(map[string]string{
 			"Error": "invalid download type",
 		})
 		return
 	}
-	if err := util.ServeDownloadables(w, r, filePath, fileName); err != nil {
+
+	data, fileName, err := h.UserService.GetDocument(r.Context(), userID, fileType)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not serve user%s %s", fileType, err.Error()),
+		})
+		return
+	}
+	if err := util.ServeDownloadables(w, r, bytes.NewReader(data), fileName); err != nil {
 		log.Println(err)
 		json.NewEncoder(w).Encode(map[string]string{
 			"Error": fmt.Sprintf("could not serve user%s %s", fileType, err.Error()),
@@ -276,10 +617,10 @@ func (h *UserHandler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	ctx, cancel := context.WithTimeout(r.Context(), util.Timeout)
+	ctx, cancel := context.WithTimeout(withActor(r, model.SourceImport), util.Timeout)
 	defer cancel()
 
-	affected, err := h.UserService.BulkUpsertUser(ctx, file)
+	report, err := h.UserService.BulkUpsertUser(ctx, file)
 	if err != nil {
 		log.Println(err)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -290,7 +631,53 @@ func (h *UserHandler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
-		"message":  "Bulk update success",
-		"affected": affected,
+		"message":    "Bulk update success",
+		"affected":   report.Affected,
+		"duplicates": report.Duplicates,
+		"warning":    report.Warning,
 	})
 }
+
+// HistoryHandler returns the audit trail of a holder as JSON.
+func (h *UserHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("uid")
+
+	entries, err := h.UserService.GetUserHistory(r.Context(), userID)
+	if err != nil {
+		log.Println(err)
+		json.NewEncoder(w).Encode(map[string]string{
+			"Error": fmt.Sprintf("could not get history of %s: %s", userID, err.Error()),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"Data": entries})
+}
+
+// HistoryPageHandler renders the audit trail of a holder.
+func (h *UserHandler) HistoryPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("uid")
+
+	entries, err := h.UserService.GetUserHistory(r.Context(), userID)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "history.html", map[string]any{
+		"UserID":   userID,
+		"IDPrefix": h.IDs.DisplayPrefix,
+		"Entries":  entries,
+	})
+}
+
+// withActor tags the request context with who is making a change. API
+// clients identify themselves with the X-Operator header, the web pages
+// send an operator form field.
+func withActor(r *http.Request, source string) context.Context {
+	if op := r.Header.Get("X-Operator"); op != "" {
+		return service.WithActor(r.Context(), model.Actor{Operator: op, Source: model.SourceAPI})
+	}
+	return service.WithActor(r.Context(), model.Actor{Operator: r.FormValue("operator"), Source: source})
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"idcard/internal/config"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimiter counts the requests of each client in fixed windows and
+	// refuses those over the limit.
+	RateLimiter struct {
+		cfg     *config.RateLimit
+		mu      sync.Mutex
+		clients map[string]*window
+		swept   time.Time
+	}
+	window struct {
+		start time.Time
+		count int
+	}
+)
+
+func NewRateLimiter(cfg *config.RateLimit) *RateLimiter {
+	return &RateLimiter{cfg: cfg, clients: map[string]*window{}, swept: time.Now()}
+}
+
+// Limit wraps next, answering 429 Too Many Requests to clients over the limit.
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(l.clientIP(r), time.Now()) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "terlalu banyak permintaan, coba lagi nanti", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (l *RateLimiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget clients whose window is over, at most once per window
+	if now.Sub(l.swept) >= l.cfg.Window {
+		for k, c := range l.clients {
+			if now.Sub(c.start) >= l.cfg.Window {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+
+	c, ok := l.clients[client]
+	if !ok || now.Sub(c.start) >= l.cfg.Window {
+		c = &window{start: now}
+		l.clients[client] = c
+	}
+	c.count++
+	return c.count <= l.cfg.Requests
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if h := l.cfg.ClientIPHeader; h != "" {
+		if ip := strings.TrimSpace(r.Header.Get(h)); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// VerifyPageHandler is the public card verification page of
+// /verify/{id}, where id is the QR code payload or a typed card number.
+// Only the status of the card and what is needed to match it to its
+// bearer are shown.
+func (h *UserHandler) VerifyPageHandler(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("id")
+	check, err := h.UserService.VerifyCard(r.Context(), ref)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "verifikasi gagal, coba lagi nanti", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	tmpl.ExecuteTemplate(w, "verify.html", map[string]any{"Check": check, "Ref": ref})
+}
+
+// VerifyFormHandler is the verification page reached without a card: it
+// offers a field to type the card number, and sends it on as /verify/{id}.
+func (h *UserHandler) VerifyFormHandler(w http.ResponseWriter, r *http.Request) {
+	if id := strings.TrimSpace(r.URL.Query().Get("id")); id != "" {
+		http.Redirect(w, r, "/verify/"+url.PathEscape(id), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+	tmpl.ExecuteTemplate(w, "verify.html", nil)
+}
@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/util"
+	"log"
+	"time"
+)
+
+// backfillNameKeys fills users.name_key for rows written before it existed.
+func backfillNameKeys(ctx context.Context, tx *sql.Tx, _ string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM users")
+	if err != nil {
+		return err
+	}
+	keys := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = util.NameKey(name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, key := range keys {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET name_key = $1 WHERE id = $2", key, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillNIKFields decodes the NIK of existing rows. Rows whose NIK does not
+// validate keep NULL fields and are reported, not rejected, so the migration
+// never blocks on legacy data.
+func backfillNIKFields(ctx context.Context, tx *sql.Tx, driver string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, nik FROM users")
+	if err != nil {
+		return err
+	}
+	decoded := map[string]*util.NIK{}
+	for rows.Next() {
+		var id, nik string
+		if err := rows.Scan(&id, &nik); err != nil {
+			rows.Close()
+			return err
+		}
+		n, err := util.ParseNIK(nik, time.Now())
+		if err != nil {
+			log.Printf("[Migrate]Skipping NIK of %s: %v", id, err)
+			continue
+		}
+		decoded[id] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, n := range decoded {
+		var birth any = n.BirthDate
+		if driver == config.DriverSQLite {
+			birth = n.BirthDate.Format(time.DateOnly)
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE users SET province_code = $1, regency_code = $2, district_code = $3, birth_date = $4, gender = $5 WHERE id = $6",
+			n.Province, n.Regency, n.District, birth, n.Gender, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillValidity treats every existing card as issued when its holder was
+// created, valid for the configured number of months from then.
+func backfillValidity(ctx context.Context, tx *sql.Tx, driver string) error {
+	validity, err := config.LoadCardValidity()
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, created_at FROM users")
+	if err != nil {
+		return err
+	}
+	created := map[string]time.Time{}
+	for rows.Next() {
+		var id string
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			rows.Close()
+			return err
+		}
+		created[id] = at
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, at := range created {
+		var issued, until any = at.UTC(), validity.Until(at)
+		if driver == config.DriverSQLite {
+			issued, until = at.UTC().Format(time.DateTime), validity.Until(at).Format(time.DateOnly)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET issued_at = $1, valid_until = $2 WHERE id = $3", issued, until, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refuseLongUserIDs stops users.id from being narrowed back to four
+// characters while IDs issued under a longer scheme would be cut.
+func refuseLongUserIDs(ctx context.Context, tx *sql.Tx, driver string) error {
+	if driver != config.DriverPostgres {
+		return nil
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE LENGTH(id) > 4").Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%d user ID(s) are longer than 4 characters; change or remove them before rolling back", n)
+	}
+	return nil
+}
+
+// refuseLongStatuses stops users.status from being narrowed back to one
+// character while holders of a longer holder type code would be cut.
+func refuseLongStatuses(ctx context.Context, tx *sql.Tx, driver string) error {
+	if driver != config.DriverPostgres {
+		return nil
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE LENGTH(TRIM(status)) > 1").Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%d user(s) have a status longer than 1 character; change or remove them before rolling back", n)
+	}
+	return nil
+}
@@ -1,52 +1,201 @@
 package migrate
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"idcard/internal/config"
+	"log"
+	"sort"
+	"time"
 )
 
-func ExecOrFail(db config.DB, query string) error {
-	_, err := db.Exec(query)
-	return err
+type (
+	// Migration is one numbered schema change with its rollback. UpFunc,
+	// when set, runs after the Up script in the same transaction for data
+	// changes SQL alone cannot express. DownFunc runs before the Down script
+	// and may refuse a rollback that would lose data.
+	Migration struct {
+		Version  int
+		Name     string
+		Up       Script
+		UpFunc   func(ctx context.Context, tx *sql.Tx, driver string) error
+		Down     Script
+		DownFunc func(ctx context.Context, tx *sql.Tx, driver string) error
+	}
+
+	// Script holds the SQL of a step for every supported dialect.
+	Script struct {
+		Postgres string
+		SQLite   string
+	}
+
+	// Status describes a known migration and when it was applied, if ever.
+	Status struct {
+		Version   int
+		Name      string
+		AppliedAt *time.Time
+	}
+)
+
+// lockID serializes migrations between app instances on Postgres
+const lockID = 7242011
+
+func (s Script) sql(driver string) string {
+	if driver == config.DriverSQLite {
+		return s.SQLite
+	}
+	return s.Postgres
 }
 
-func CreateTable(db config.DB) error {
-	query := `CREATE TABLE IF NOT EXISTS users (
-		id VARCHAR(4) PRIMARY KEY NOT NULL,
-		nik VARCHAR(16) NOT NULL UNIQUE,
-		status CHAR(1) NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		phone VARCHAR(20) NOT NULL,
-		address VARCHAR(255) NOT NULL,
-		rating INTEGER DEFAULT 0,
-		notes TEXT DEFAULT NULL,
-		photo VARCHAR(255) NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
+// Up applies every pending migration in version order and returns how many ran.
+func Up(ctx context.Context, db config.DB) (int, error) {
+	if err := ensureTable(ctx, db); err != nil {
+		return 0, err
+	}
 
-	trigger := `CREATE TRIGGER IF NOT EXISTS update_users_updated_at
-		AFTER UPDATE ON users
-		FOR EACH ROW
-		BEGIN
-		UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
-		END;`
+	ran := 0
+	for _, m := range sorted() {
+		applied, err := step(ctx, db, m, true)
+		if err != nil {
+			return ran, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			log.Printf("[Migrate]Applied %04d_%s", m.Version, m.Name)
+			ran++
+		}
+	}
+	return ran, nil
+}
 
-	idxNik := `CREATE INDEX IF NOT EXISTS idx_users_nik ON users(nik);`
+// Down rolls back the latest n applied migrations.
+func Down(ctx context.Context, db config.DB, n int) (int, error) {
+	if err := ensureTable(ctx, db); err != nil {
+		return 0, err
+	}
 
-	idxStatus := `CREATE INDEX IF NOT EXISTS idx_users_sopir ON users(status)
-		WHERE status = 'S';`
+	migrations := sorted()
+	ran := 0
+	for i := len(migrations) - 1; i >= 0 && ran < n; i-- {
+		m := migrations[i]
+		reverted, err := step(ctx, db, m, false)
+		if err != nil {
+			return ran, fmt.Errorf("rollback %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if reverted {
+			log.Printf("[Migrate]Rolled back %04d_%s", m.Version, m.Name)
+			ran++
+		}
+	}
+	return ran, nil
+}
 
-	if err := ExecOrFail(db, query); err != nil {
-		return err
+// Statuses lists every known migration with its applied time.
+func Statuses(ctx context.Context, db config.DB) ([]Status, error) {
+	if err := ensureTable(ctx, db); err != nil {
+		return nil, err
 	}
-	if err := ExecOrFail(db, trigger); err != nil {
-		return err
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
 	}
-	if err := ExecOrFail(db, idxNik); err != nil {
-		return err
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			v int
+			t time.Time
+		)
+		if err := rows.Scan(&v, &t); err != nil {
+			return nil, err
+		}
+		applied[v] = t
 	}
-	if err := ExecOrFail(db, idxStatus); err != nil {
-		return err
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	return nil
+
+	res := []Status{}
+	for _, m := range sorted() {
+		st := Status{Version: m.Version, Name: m.Name}
+		if t, ok := applied[m.Version]; ok {
+			st.AppliedAt = &t
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+// step applies (up) or reverts (down) m in its own transaction. It reports
+// false when there was nothing to do, e.g. another instance got there first.
+func step(ctx context.Context, db config.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if db.Driver() == config.DriverPostgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockID); err != nil {
+			return false, err
+		}
+	}
+
+	var v int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM schema_migrations WHERE version = $1", m.Version).Scan(&v)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	isApplied := err == nil
+	if isApplied == up {
+		return false, nil
+	}
+
+	if !up && m.DownFunc != nil {
+		if err := m.DownFunc(ctx, tx, db.Driver()); err != nil {
+			return false, err
+		}
+	}
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	if q := script.sql(db.Driver()); q != "" {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return false, err
+		}
+	}
+	if up && m.UpFunc != nil {
+		if err := m.UpFunc(ctx, tx, db.Driver()); err != nil {
+			return false, err
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func ensureTable(ctx context.Context, db config.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func sorted() []Migration {
+	res := append([]Migration(nil), migrations...)
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res
 }
@@ -0,0 +1,469 @@
+package migrate
+
+// migrations is the schema history. Append new steps with the next version
+// number; never edit a step that has shipped.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_users",
+		Up: Script{
+			Postgres: `CREATE TABLE IF NOT EXISTS users (
+				id VARCHAR(4) PRIMARY KEY NOT NULL,
+				nik VARCHAR(16) NOT NULL UNIQUE,
+				status CHAR(1) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				phone VARCHAR(20) NOT NULL,
+				address VARCHAR(255) NOT NULL,
+				rating INTEGER DEFAULT 0,
+				notes TEXT DEFAULT NULL,
+				photo VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_users_nik ON users(nik);
+			CREATE INDEX IF NOT EXISTS idx_users_sopir ON users(status) WHERE status = 'S';`,
+			SQLite: `CREATE TABLE IF NOT EXISTS users (
+				id VARCHAR(4) PRIMARY KEY NOT NULL,
+				nik VARCHAR(16) NOT NULL UNIQUE,
+				status CHAR(1) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				phone VARCHAR(20) NOT NULL,
+				address VARCHAR(255) NOT NULL,
+				rating INTEGER DEFAULT 0,
+				notes TEXT DEFAULT NULL,
+				photo VARCHAR(255) NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_users_nik ON users(nik);
+			CREATE INDEX IF NOT EXISTS idx_users_sopir ON users(status) WHERE status = 'S';`,
+		},
+		Down: Script{
+			Postgres: `DROP TABLE IF EXISTS users;`,
+			SQLite:   `DROP TABLE IF EXISTS users;`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_user_id_sequences",
+		Up: Script{
+			Postgres: `CREATE TABLE user_id_sequences (
+				scope VARCHAR(32) PRIMARY KEY NOT NULL,
+				last_value INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE TABLE user_id_reservations (
+				id VARCHAR(32) PRIMARY KEY NOT NULL,
+				scope VARCHAR(32) NOT NULL,
+				token VARCHAR(64) NOT NULL UNIQUE,
+				expires_at TIMESTAMP NOT NULL
+			);
+			CREATE INDEX idx_user_id_reservations_scope ON user_id_reservations(scope, expires_at);
+			INSERT INTO user_id_sequences (scope, last_value)
+				SELECT status, MAX(CAST(SUBSTRING(id FROM 2) AS INTEGER)) FROM users GROUP BY status;`,
+			SQLite: `CREATE TABLE user_id_sequences (
+				scope VARCHAR(32) PRIMARY KEY NOT NULL,
+				last_value INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE TABLE user_id_reservations (
+				id VARCHAR(32) PRIMARY KEY NOT NULL,
+				scope VARCHAR(32) NOT NULL,
+				token VARCHAR(64) NOT NULL UNIQUE,
+				expires_at TIMESTAMP NOT NULL
+			);
+			CREATE INDEX idx_user_id_reservations_scope ON user_id_reservations(scope, expires_at);
+			INSERT INTO user_id_sequences (scope, last_value)
+				SELECT status, MAX(CAST(SUBSTR(id, 2) AS INTEGER)) FROM users GROUP BY status;`,
+		},
+		Down: Script{
+			Postgres: `DROP TABLE IF EXISTS user_id_reservations;
+			DROP TABLE IF EXISTS user_id_sequences;`,
+			SQLite: `DROP TABLE IF EXISTS user_id_reservations;
+			DROP TABLE IF EXISTS user_id_sequences;`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "widen_user_ids",
+		// SQLite does not enforce VARCHAR lengths, so only Postgres needs this
+		Up: Script{
+			Postgres: `ALTER TABLE users ALTER COLUMN id TYPE VARCHAR(32);`,
+		},
+		Down: Script{
+			Postgres: `ALTER TABLE users ALTER COLUMN id TYPE VARCHAR(4);`,
+		},
+		DownFunc: refuseLongUserIDs,
+	},
+	{
+		Version: 4,
+		Name:    "add_users_active",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+			ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL;
+			ALTER TABLE users ADD COLUMN delete_reason TEXT NULL;
+			CREATE INDEX idx_users_active ON users(active, updated_at);`,
+			SQLite: `ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+			ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL;
+			ALTER TABLE users ADD COLUMN delete_reason TEXT NULL;
+			CREATE INDEX idx_users_active ON users(active, updated_at);`,
+		},
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_active;
+			ALTER TABLE users DROP COLUMN delete_reason;
+			ALTER TABLE users DROP COLUMN deleted_at;
+			ALTER TABLE users DROP COLUMN active;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_active;
+			ALTER TABLE users DROP COLUMN delete_reason;
+			ALTER TABLE users DROP COLUMN deleted_at;
+			ALTER TABLE users DROP COLUMN active;`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_user_audit",
+		Up: Script{
+			Postgres: `CREATE TABLE user_audit (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(32) NOT NULL,
+				action VARCHAR(16) NOT NULL,
+				source VARCHAR(16) NOT NULL,
+				operator VARCHAR(255) NOT NULL,
+				changes TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_user_audit_user ON user_audit(user_id, id);`,
+			SQLite: `CREATE TABLE user_audit (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id VARCHAR(32) NOT NULL,
+				action VARCHAR(16) NOT NULL,
+				source VARCHAR(16) NOT NULL,
+				operator VARCHAR(255) NOT NULL,
+				changes TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_user_audit_user ON user_audit(user_id, id);`,
+		},
+		Down: Script{
+			Postgres: `DROP TABLE IF EXISTS user_audit;`,
+			SQLite:   `DROP TABLE IF EXISTS user_audit;`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "index_users_listing",
+		Up: Script{
+			Postgres: `CREATE INDEX idx_users_active_created ON users(active, created_at, id);
+			CREATE INDEX idx_users_active_name ON users(active, name, id);`,
+			SQLite: `CREATE INDEX idx_users_active_created ON users(active, created_at, id);
+			CREATE INDEX idx_users_active_name ON users(active, name, id);`,
+		},
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_active_name;
+			DROP INDEX IF EXISTS idx_users_active_created;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_active_name;
+			DROP INDEX IF EXISTS idx_users_active_created;`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_users_name_key",
+		Up: Script{
+			Postgres: `CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			ALTER TABLE users ADD COLUMN name_key VARCHAR(255) NOT NULL DEFAULT '';
+			CREATE INDEX idx_users_name_key_trgm ON users USING GIN (name_key gin_trgm_ops);`,
+			SQLite: `ALTER TABLE users ADD COLUMN name_key VARCHAR(255) NOT NULL DEFAULT '';`,
+		},
+		UpFunc: backfillNameKeys,
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_name_key_trgm;
+			ALTER TABLE users DROP COLUMN name_key;`,
+			SQLite: `ALTER TABLE users DROP COLUMN name_key;`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_users_nik_fields",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN province_code CHAR(2) NULL;
+			ALTER TABLE users ADD COLUMN regency_code CHAR(4) NULL;
+			ALTER TABLE users ADD COLUMN district_code CHAR(6) NULL;
+			ALTER TABLE users ADD COLUMN birth_date DATE NULL;
+			ALTER TABLE users ADD COLUMN gender CHAR(1) NULL;
+			CREATE INDEX idx_users_region ON users(province_code, regency_code);
+			CREATE INDEX idx_users_birth_date ON users(birth_date);`,
+			SQLite: `ALTER TABLE users ADD COLUMN province_code CHAR(2) NULL;
+			ALTER TABLE users ADD COLUMN regency_code CHAR(4) NULL;
+			ALTER TABLE users ADD COLUMN district_code CHAR(6) NULL;
+			ALTER TABLE users ADD COLUMN birth_date DATE NULL;
+			ALTER TABLE users ADD COLUMN gender CHAR(1) NULL;
+			CREATE INDEX idx_users_region ON users(province_code, regency_code);
+			CREATE INDEX idx_users_birth_date ON users(birth_date);`,
+		},
+		UpFunc: backfillNIKFields,
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_birth_date;
+			DROP INDEX IF EXISTS idx_users_region;
+			ALTER TABLE users DROP COLUMN gender;
+			ALTER TABLE users DROP COLUMN birth_date;
+			ALTER TABLE users DROP COLUMN district_code;
+			ALTER TABLE users DROP COLUMN regency_code;
+			ALTER TABLE users DROP COLUMN province_code;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_birth_date;
+			DROP INDEX IF EXISTS idx_users_region;
+			ALTER TABLE users DROP COLUMN gender;
+			ALTER TABLE users DROP COLUMN birth_date;
+			ALTER TABLE users DROP COLUMN district_code;
+			ALTER TABLE users DROP COLUMN regency_code;
+			ALTER TABLE users DROP COLUMN province_code;`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "add_users_merged_into",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN merged_into VARCHAR(32) NULL;
+			CREATE INDEX idx_users_phone ON users(phone);`,
+			SQLite: `ALTER TABLE users ADD COLUMN merged_into VARCHAR(32) NULL;
+			CREATE INDEX idx_users_phone ON users(phone);`,
+		},
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_phone;
+			ALTER TABLE users DROP COLUMN merged_into;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_phone;
+			ALTER TABLE users DROP COLUMN merged_into;`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "create_holder_types",
+		Up: Script{
+			Postgres: `CREATE TABLE holder_types (
+				code VARCHAR(8) PRIMARY KEY NOT NULL,
+				label VARCHAR(64) NOT NULL,
+				id_prefix VARCHAR(8) NOT NULL UNIQUE,
+				card_template VARCHAR(255) NOT NULL,
+				pdf_form VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			INSERT INTO holder_types (code, label, id_prefix, card_template, pdf_form) VALUES
+				('S', 'Penyetor', 'S', 'static/assets/kartu.png', 'Penyetor Afval'),
+				('V', 'Vendor', 'V', 'static/assets/kartu.png', 'Penyetor Afval');
+			INSERT INTO holder_types (code, label, id_prefix, card_template, pdf_form)
+				SELECT DISTINCT TRIM(status), TRIM(status), TRIM(status), 'static/assets/kartu.png', 'Penyetor Afval'
+				FROM users WHERE TRIM(status) NOT IN ('S', 'V');
+			DROP INDEX IF EXISTS idx_users_sopir;
+			ALTER TABLE users ALTER COLUMN status TYPE VARCHAR(8);
+			CREATE INDEX idx_users_status ON users(status);`,
+			SQLite: `CREATE TABLE holder_types (
+				code VARCHAR(8) PRIMARY KEY NOT NULL,
+				label VARCHAR(64) NOT NULL,
+				id_prefix VARCHAR(8) NOT NULL UNIQUE,
+				card_template VARCHAR(255) NOT NULL,
+				pdf_form VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			INSERT INTO holder_types (code, label, id_prefix, card_template, pdf_form) VALUES
+				('S', 'Penyetor', 'S', 'static/assets/kartu.png', 'Penyetor Afval'),
+				('V', 'Vendor', 'V', 'static/assets/kartu.png', 'Penyetor Afval');
+			INSERT INTO holder_types (code, label, id_prefix, card_template, pdf_form)
+				SELECT DISTINCT status, status, status, 'static/assets/kartu.png', 'Penyetor Afval'
+				FROM users WHERE status NOT IN ('S', 'V');
+			DROP INDEX IF EXISTS idx_users_sopir;
+			CREATE INDEX idx_users_status ON users(status);`,
+		},
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_status;
+			ALTER TABLE users ALTER COLUMN status TYPE CHAR(1);
+			CREATE INDEX IF NOT EXISTS idx_users_sopir ON users(status) WHERE status = 'S';
+			DROP TABLE IF EXISTS holder_types;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_status;
+			CREATE INDEX IF NOT EXISTS idx_users_sopir ON users(status) WHERE status = 'S';
+			DROP TABLE IF EXISTS holder_types;`,
+		},
+		DownFunc: refuseLongStatuses,
+	},
+	{
+		Version: 11,
+		Name:    "add_users_validity",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN issued_at TIMESTAMP NULL;
+			ALTER TABLE users ADD COLUMN valid_until DATE NULL;
+			CREATE INDEX idx_users_valid_until ON users(valid_until);`,
+			SQLite: `ALTER TABLE users ADD COLUMN issued_at TIMESTAMP NULL;
+			ALTER TABLE users ADD COLUMN valid_until DATE NULL;
+			CREATE INDEX idx_users_valid_until ON users(valid_until);`,
+		},
+		UpFunc: backfillValidity,
+		Down: Script{
+			Postgres: `DROP INDEX IF EXISTS idx_users_valid_until;
+			ALTER TABLE users DROP COLUMN valid_until;
+			ALTER TABLE users DROP COLUMN issued_at;`,
+			SQLite: `DROP INDEX IF EXISTS idx_users_valid_until;
+			ALTER TABLE users DROP COLUMN valid_until;
+			ALTER TABLE users DROP COLUMN issued_at;`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "add_holder_states",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN state VARCHAR(16) NOT NULL DEFAULT 'active';
+			ALTER TABLE users ADD COLUMN state_reason TEXT NULL;
+			ALTER TABLE users ADD COLUMN state_from DATE NULL;
+			ALTER TABLE users ADD COLUMN state_until DATE NULL;
+			CREATE INDEX idx_users_state ON users(state);
+			CREATE TABLE nik_blacklist (
+				nik VARCHAR(16) PRIMARY KEY NOT NULL,
+				user_id VARCHAR(32) NOT NULL,
+				reason TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+			SQLite: `ALTER TABLE users ADD COLUMN state VARCHAR(16) NOT NULL DEFAULT 'active';
+			ALTER TABLE users ADD COLUMN state_reason TEXT NULL;
+			ALTER TABLE users ADD COLUMN state_from DATE NULL;
+			ALTER TABLE users ADD COLUMN state_until DATE NULL;
+			CREATE INDEX idx_users_state ON users(state);
+			CREATE TABLE nik_blacklist (
+				nik VARCHAR(16) PRIMARY KEY NOT NULL,
+				user_id VARCHAR(32) NOT NULL,
+				reason TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+		Down: Script{
+			Postgres: `DROP TABLE IF EXISTS nik_blacklist;
+			DROP INDEX IF EXISTS idx_users_state;
+			ALTER TABLE users DROP COLUMN state_until;
+			ALTER TABLE users DROP COLUMN state_from;
+			ALTER TABLE users DROP COLUMN state_reason;
+			ALTER TABLE users DROP COLUMN state;`,
+			SQLite: `DROP TABLE IF EXISTS nik_blacklist;
+			DROP INDEX IF EXISTS idx_users_state;
+			ALTER TABLE users DROP COLUMN state_until;
+			ALTER TABLE users DROP COLUMN state_from;
+			ALTER TABLE users DROP COLUMN state_reason;
+			ALTER TABLE users DROP COLUMN state;`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "create_inspections",
+		Up: Script{
+			Postgres: `CREATE TABLE inspections (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(32) NOT NULL,
+				inspected_on DATE NOT NULL,
+				grade VARCHAR(8) NOT NULL,
+				moisture REAL NULL,
+				contamination REAL NULL,
+				inspector VARCHAR(255) NOT NULL,
+				notes TEXT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_inspections_user ON inspections(user_id, inspected_on);
+			ALTER TABLE users ADD COLUMN rated_at TIMESTAMP NULL;`,
+			SQLite: `CREATE TABLE inspections (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id VARCHAR(32) NOT NULL,
+				inspected_on DATE NOT NULL,
+				grade VARCHAR(8) NOT NULL,
+				moisture REAL NULL,
+				contamination REAL NULL,
+				inspector VARCHAR(255) NOT NULL,
+				notes TEXT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_inspections_user ON inspections(user_id, inspected_on);
+			ALTER TABLE users ADD COLUMN rated_at TIMESTAMP NULL;`,
+		},
+		Down: Script{
+			Postgres: `ALTER TABLE users DROP COLUMN rated_at;
+			DROP TABLE IF EXISTS inspections;`,
+			SQLite: `ALTER TABLE users DROP COLUMN rated_at;
+			DROP TABLE IF EXISTS inspections;`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "create_deliveries",
+		Up: Script{
+			Postgres: `CREATE TABLE deliveries (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(32) NOT NULL,
+				delivered_on DATE NOT NULL,
+				vehicle VARCHAR(16) NOT NULL,
+				material VARCHAR(64) NOT NULL,
+				gross_kg REAL NOT NULL,
+				tare_kg REAL NOT NULL,
+				net_kg REAL NOT NULL,
+				inspection_id BIGINT NULL,
+				notes TEXT NULL,
+				operator VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_deliveries_user ON deliveries(user_id, delivered_on);
+			CREATE INDEX idx_deliveries_date ON deliveries(delivered_on);`,
+			SQLite: `CREATE TABLE deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id VARCHAR(32) NOT NULL,
+				delivered_on DATE NOT NULL,
+				vehicle VARCHAR(16) NOT NULL,
+				material VARCHAR(64) NOT NULL,
+				gross_kg REAL NOT NULL,
+				tare_kg REAL NOT NULL,
+				net_kg REAL NOT NULL,
+				inspection_id INTEGER NULL,
+				notes TEXT NULL,
+				operator VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX idx_deliveries_user ON deliveries(user_id, delivered_on);
+			CREATE INDEX idx_deliveries_date ON deliveries(delivered_on);`,
+		},
+		Down: Script{
+			Postgres: `DROP TABLE IF EXISTS deliveries;`,
+			SQLite:   `DROP TABLE IF EXISTS deliveries;`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "add_users_card_issue",
+		Up: Script{
+			Postgres: `ALTER TABLE users ADD COLUMN card_issue INTEGER NOT NULL DEFAULT 1;`,
+			SQLite:   `ALTER TABLE users ADD COLUMN card_issue INTEGER NOT NULL DEFAULT 1;`,
+		},
+		Down: Script{
+			Postgres: `ALTER TABLE users DROP COLUMN card_issue;`,
+			SQLite:   `ALTER TABLE users DROP COLUMN card_issue;`,
+		},
+	},
+	{
+		Version: 16,
+		Name:    "add_holder_types_back_template",
+		Up: Script{
+			Postgres: `ALTER TABLE holder_types ADD COLUMN back_template VARCHAR(255) NOT NULL DEFAULT 'static/assets/BACK.png';`,
+			SQLite:   `ALTER TABLE holder_types ADD COLUMN back_template VARCHAR(255) NOT NULL DEFAULT 'static/assets/BACK.png';`,
+		},
+		Down: Script{
+			Postgres: `ALTER TABLE holder_types DROP COLUMN back_template;`,
+			SQLite:   `ALTER TABLE holder_types DROP COLUMN back_template;`,
+		},
+	},
+	{
+		Version: 17,
+		Name:    "alter_deliveries_weights_numeric",
+		// Postgres REAL is a 4-byte float, too coarse for weights summed over
+		// months; SQLite REAL is already 8 bytes, so only Postgres needs this
+		Up: Script{
+			Postgres: `ALTER TABLE deliveries ALTER COLUMN gross_kg TYPE NUMERIC(12,2);
+			ALTER TABLE deliveries ALTER COLUMN tare_kg TYPE NUMERIC(12,2);
+			ALTER TABLE deliveries ALTER COLUMN net_kg TYPE NUMERIC(12,2);`,
+		},
+		Down: Script{
+			Postgres: `ALTER TABLE deliveries ALTER COLUMN gross_kg TYPE REAL;
+			ALTER TABLE deliveries ALTER COLUMN tare_kg TYPE REAL;
+			ALTER TABLE deliveries ALTER COLUMN net_kg TYPE REAL;`,
+		},
+	},
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+const (
+	AuditCreate     = "create"
+	AuditUpdate     = "update"
+	AuditUpsert     = "upsert"
+	AuditDeactivate = "deactivate"
+	AuditRestore    = "restore"
+	AuditDelete     = "delete"
+	AuditMerge      = "merge"
+	AuditRenew      = "renew"
+	AuditState      = "state"
+	AuditInspect    = "inspect"
+
+	SourceForm   = "form"
+	SourceImport = "xlsx"
+	SourceAPI    = "api"
+	SourceCLI    = "cli"
+)
+
+type (
+	// Actor is who made a change and through which channel.
+	Actor struct {
+		Operator string
+		Source   string
+	}
+
+	// FieldChange is the value of one field before and after a change.
+	FieldChange struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}
+
+	AuditEntry struct {
+		ID        int64
+		UserID    string
+		Action    string
+		Source    string
+		Operator  string
+		Changes   map[string]FieldChange
+		CreatedAt time.Time
+	}
+)
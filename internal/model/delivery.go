@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// Delivery is one setoran: what a holder brought in on DeliveredOn, weighed
+// gross and tare in kilograms. InspectionID links the QC inspection of the
+// load, whose grade is Grade.
+type Delivery struct {
+	ID           int64
+	UserID       string
+	DeliveredOn  time.Time
+	Vehicle      string
+	Material     string
+	GrossKg      float64
+	TareKg       float64
+	NetKg        float64
+	InspectionID *int64
+	Notes        string
+	Operator     string
+	CreatedAt    time.Time
+
+	// Joined from the holder and the inspection when listed
+	Name  string
+	Grade string
+}
+
+// DeliveryFilter narrows deliveries to one holder, material and an
+// inclusive date range. Zero values mean no filtering.
+type DeliveryFilter struct {
+	UserID   string
+	Material string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// DeliveryTotal sums the deliveries of one holder or one period, named by Key.
+type DeliveryTotal struct {
+	Key     string
+	Name    string `json:",omitempty"`
+	Count   int
+	GrossKg float64
+	TareKg  float64
+	NetKg   float64
+}
+
+// DeliveryReport is a delivery log with its totals.
+type DeliveryReport struct {
+	Deliveries []Delivery
+	ByHolder   []DeliveryTotal
+	ByPeriod   []DeliveryTotal
+	Total      DeliveryTotal
+}
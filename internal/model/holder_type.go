@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// HolderType is a kind of card holder such as Penyetor or Vendor. Code is
+// stored in users.status; IDPrefix starts the IDs issued for it; CardTemplate
+// and BackTemplate are the backgrounds of the two sides of its card, and
+// PDFForm the role named on its registration form.
+type HolderType struct {
+	Code         string
+	Label        string
+	IDPrefix     string
+	CardTemplate string
+	PDFForm      string
+	BackTemplate string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
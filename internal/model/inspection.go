@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Inspection is one QC check of a delivery by a holder. Moisture and
+// Contamination are percentages, nil when not measured.
+type Inspection struct {
+	ID            int64
+	UserID        string
+	InspectedOn   time.Time
+	Grade         string
+	Moisture      *float64
+	Contamination *float64
+	Inspector     string
+	Notes         string
+	CreatedAt     time.Time
+
+	// Score is computed with the current rating formula, not stored
+	Score int
+}
+
+// RatingPoint is the rolling rating of a holder right after one inspection.
+type RatingPoint struct {
+	Date   time.Time
+	Score  int
+	Rating int
+}
+
+// InspectionHistory is the QC record of a holder: inspections newest first
+// and the rating trend oldest first.
+type InspectionHistory struct {
+	Rating      int
+	Inspections []Inspection
+	Trend       []RatingPoint
+}
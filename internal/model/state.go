@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+// Lifecycle states of a holder. Gate staff refuse entry to suspended and
+// blacklisted holders; a blacklisted NIK cannot register again.
+const (
+	StateActive      = "active"
+	StateSuspended   = "suspended"
+	StateBlacklisted = "blacklisted"
+)
+
+// StateChange moves a holder to State for Reason. EffectiveFrom defaults to
+// today; EffectiveUntil only applies to suspensions, which lapse after it.
+type StateChange struct {
+	State          string
+	Reason         string
+	EffectiveFrom  *time.Time
+	EffectiveUntil *time.Time
+}
+
+// BlacklistedNIK is a NIK barred from registering, with the holder that was
+// blacklisted under it.
+type BlacklistedNIK struct {
+	NIK       string
+	UserID    string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// CardScan is the verdict on a card QR code read at the gate. Valid means
+// the code is genuine and from the holder's latest card; Admitted means the
+// holder may also enter today. Reason explains a refusal. User is only set
+// when the code is genuine.
+type CardScan struct {
+	Valid    bool
+	Admitted bool
+	Reason   string
+	Issue    int
+	User     *User
+}
+
+// CardLookup is what the barcode lookup of the weighbridge returns: enough
+// to match the bearer and to accept or refuse the load, without the NIK,
+// phone or address of the holder.
+type CardLookup struct {
+	CardNo     string
+	Name       string
+	HolderType string
+	Photo      string
+	Admitted   bool
+	Reason     string
+}
+
+// Card statuses shown on the public verification page.
+const (
+	CardValid     = "valid"
+	CardExpired   = "expired"
+	CardSuspended = "suspended"
+	CardRevoked   = "revoked"
+	CardReplaced  = "replaced"
+	CardInvalid   = "invalid"
+	CardUnknown   = "unknown"
+)
+
+// CardCheck is what the public verification page may show about a card.
+// Signed tells a check of the QR code, which proves the card genuine, from
+// one of a typed card number, which only proves the holder exists. The
+// holder fields stay empty for invalid and unknown cards.
+type CardCheck struct {
+	Status     string
+	Signed     bool
+	CardNo     string
+	MaskedName string
+	HolderType string
+	Photo      string
+	ValidUntil *time.Time
+}
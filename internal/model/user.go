@@ -3,15 +3,175 @@ package model
 import "time"
 
 type User struct {
-	ID        string
-	Status    string
-	NIK       string
-	Name      string
-	Phone     string
-	Address   string
-	Rating    int
+	ID      string
+	Status  string
+	NIK     string
+	Name    string
+	Phone   string
+	Address string
+	Rating  int
+	// RatedAt is when QC inspections last set Rating; from then on the
+	// rating is no longer edited by hand
+	RatedAt   *time.Time
 	Notes     string
 	Photo     string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Active is false once the holder is soft deleted
+	Active       bool
+	DeletedAt    *time.Time
+	DeleteReason string
+	// MergedInto is the surviving ID once this holder was merged as a duplicate
+	MergedInto string
+
+	// Decoded from NIK; empty for rows imported before validation existed
+	ProvinceCode string
+	RegencyCode  string
+	DistrictCode string
+	BirthDate    *time.Time
+	Gender       string
+
+	// IssuedAt is when the current card was printed; the card is valid
+	// through ValidUntil. CardIssue counts the cards issued to the holder,
+	// the QR code of earlier ones no longer scans as valid
+	IssuedAt   *time.Time
+	ValidUntil *time.Time
+	CardIssue  int
+	// Computed from ValidUntil when the holder is read, not stored
+	Expired      bool
+	ExpiringSoon bool
+
+	// State is the lifecycle state, see StateActive; it applies from
+	// StateFrom and, for suspensions, through StateUntil
+	State       string
+	StateReason string
+	StateFrom   *time.Time
+	StateUntil  *time.Time
+}
+
+const (
+	ValidityExpired  = "expired"
+	ValidityExpiring = "expiring"
+)
+
+// IDReservation holds a user ID for the registration form until ExpiresAt.
+type IDReservation struct {
+	ID        string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// UserFilter narrows and orders the card holder list. Zero values mean no
+// filtering; Cursor continues from the last row of a previous page.
+type UserFilter struct {
+	Query       string
+	Status      string
+	Inactive    bool
+	MinRating   *int
+	MaxRating   *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Province    string
+	Regency     string
+	Gender      string
+	MinAge      *int
+	MaxAge      *int
+	// Validity is ValidityExpired or ValidityExpiring; the service turns it
+	// into the ValidUntil bounds
+	Validity       string
+	State          string
+	ValidUntilFrom *time.Time
+	ValidUntilTo   *time.Time
+	Sort           string
+	Desc           bool
+	Cursor         string
+	Limit          int
+}
+
+// UserPage is one page of the card holder list.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
+
+// NameMatch is a fuzzy name search hit, Score between 0 and 1.
+type NameMatch struct {
+	User
+	Score float64
+}
+
+// NameMatchPage is one page of fuzzy name search hits.
+type NameMatchPage struct {
+	Matches    []NameMatch
+	NextCursor string
+}
+
+// RegionCount is the number of holders from one province or regency.
+type RegionCount struct {
+	Code  string
+	Name  string `json:",omitempty"`
+	Count int
+}
+
+// AgeGroupCount is the number of holders whose age falls in Label.
+type AgeGroupCount struct {
+	Label string
+	Count int
+}
+
+// DemographicsReport breaks active holders down by the data in their NIK.
+// Unknown counts holders whose NIK could not be decoded.
+type DemographicsReport struct {
+	Total     int
+	Unknown   int
+	Genders   map[string]int
+	Provinces []RegionCount
+	Regencies []RegionCount
+	AgeGroups []AgeGroupCount
+}
+
+// DemographicsRow is the number of holders sharing region, gender and birth date.
+type DemographicsRow struct {
+	ProvinceCode string
+	RegencyCode  string
+	Gender       string
+	BirthDate    *time.Time
+	Count        int
+}
+
+// PhoneNormalization summarises a normalize-phones run. Invalid maps holder
+// IDs to why their phone could not be normalized.
+type PhoneNormalization struct {
+	Checked int
+	Changed int
+	Invalid map[string]string
+}
+
+const (
+	DuplicateNIK         = "nik"
+	DuplicatePhone       = "phone"
+	DuplicateNameAddress = "name_address"
+)
+
+// DuplicateCandidate is an existing holder that may be the same person,
+// with the checks that matched.
+type DuplicateCandidate struct {
+	User
+	Reasons []string
+}
+
+// ImportDuplicate lists possible duplicates of one imported row.
+type ImportDuplicate struct {
+	ID         string
+	NIK        string
+	Candidates []DuplicateCandidate
+}
+
+// ImportReport is the outcome of an XLSX import. Warning is set when the
+// rows were saved but could not be checked for duplicates.
+type ImportReport struct {
+	Affected   int
+	Duplicates []ImportDuplicate
+	Warning    string
 }
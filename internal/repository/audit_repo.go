@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"idcard/internal/config"
+	"idcard/internal/model"
+)
+
+type (
+	AuditRepository interface {
+		Create(ctx context.Context, tx *sql.Tx, e *model.AuditEntry) error
+		ListByUser(ctx context.Context, userID string, limit int) ([]model.AuditEntry, error)
+		Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error)
+	}
+	auditRepo struct {
+		db config.DB
+	}
+)
+
+func NewAuditRepository(database config.DB) AuditRepository {
+	return &auditRepo{db: database}
+}
+
+// Create records e in the same transaction as the change it describes.
+func (r *auditRepo) Create(ctx context.Context, tx *sql.Tx, e *model.AuditEntry) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_audit (user_id, action, source, operator, changes) VALUES ($1, $2, $3, $4, $5)",
+		e.UserID, e.Action, e.Source, e.Operator, string(changes))
+	return err
+}
+
+// Reassign moves every entry of from to to, e.g. when holders are merged.
+func (r *auditRepo) Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE user_audit SET user_id = $1 WHERE user_id = $2", to, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListByUser returns the newest entries of userID first.
+func (r *auditRepo) ListByUser(ctx context.Context, userID string, limit int) ([]model.AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, user_id, action, source, operator, changes, created_at FROM user_audit WHERE user_id = $1 ORDER BY id DESC LIMIT $2", userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var (
+			e       model.AuditEntry
+			changes string
+		)
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Action, &e.Source, &e.Operator, &changes, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
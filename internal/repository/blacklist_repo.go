@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idcard/internal/config"
+	"idcard/internal/model"
+)
+
+type (
+	BlacklistRepository interface {
+		Add(ctx context.Context, tx *sql.Tx, b *model.BlacklistedNIK) error
+		Remove(ctx context.Context, tx *sql.Tx, nik string) error
+		Get(ctx context.Context, nik string) (*model.BlacklistedNIK, error)
+		List(ctx context.Context) ([]model.BlacklistedNIK, error)
+	}
+	blacklistRepo struct {
+		db config.DB
+	}
+)
+
+const blacklistColumns = "nik, user_id, reason, created_at"
+
+func NewBlacklistRepository(database config.DB) BlacklistRepository {
+	return &blacklistRepo{db: database}
+}
+
+// Add bars b.NIK, replacing the reason when it is already barred.
+func (r *blacklistRepo) Add(ctx context.Context, tx *sql.Tx, b *model.BlacklistedNIK) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO nik_blacklist (nik, user_id, reason) VALUES ($1, $2, $3)
+		ON CONFLICT (nik) DO UPDATE SET user_id = EXCLUDED.user_id, reason = EXCLUDED.reason`, b.NIK, b.UserID, b.Reason)
+	return err
+}
+
+func (r *blacklistRepo) Remove(ctx context.Context, tx *sql.Tx, nik string) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM nik_blacklist WHERE nik = $1", nik)
+	return err
+}
+
+// Get returns the entry of nik, or sql.ErrNoRows when it is not barred.
+func (r *blacklistRepo) Get(ctx context.Context, nik string) (*model.BlacklistedNIK, error) {
+	return scanBlacklisted(r.db.QueryRowContext(ctx, "SELECT "+blacklistColumns+" FROM nik_blacklist WHERE nik = $1", nik))
+}
+
+func (r *blacklistRepo) List(ctx context.Context) ([]model.BlacklistedNIK, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+blacklistColumns+" FROM nik_blacklist ORDER BY nik")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.BlacklistedNIK{}
+	for rows.Next() {
+		b, err := scanBlacklisted(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *b)
+	}
+	return list, rows.Err()
+}
+
+func scanBlacklisted(row rowScanner) (*model.BlacklistedNIK, error) {
+	var b model.BlacklistedNIK
+	if err := row.Scan(&b.NIK, &b.UserID, &b.Reason, &b.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
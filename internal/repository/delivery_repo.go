@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"strings"
+	"time"
+)
+
+type (
+	DeliveryRepository interface {
+		Create(ctx context.Context, tx *sql.Tx, d *model.Delivery) error
+		List(ctx context.Context, f model.DeliveryFilter) ([]model.Delivery, error)
+		TotalsByHolder(ctx context.Context, f model.DeliveryFilter) ([]model.DeliveryTotal, error)
+		TotalsByDay(ctx context.Context, f model.DeliveryFilter) ([]model.DeliveryTotal, error)
+		Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error)
+		CountByUser(ctx context.Context, tx *sql.Tx, userID string) (int, error)
+	}
+	deliveryRepo struct {
+		db config.DB
+	}
+)
+
+const (
+	deliveryColumns = "d.id, d.user_id, COALESCE(u.name, ''), d.delivered_on, d.vehicle, d.material, d.gross_kg, d.tare_kg, d.net_kg, " +
+		"d.inspection_id, COALESCE(i.grade, ''), COALESCE(d.notes, ''), d.operator, d.created_at"
+	deliveryJoins = " FROM deliveries d LEFT JOIN users u ON u.id = d.user_id LEFT JOIN inspections i ON i.id = d.inspection_id"
+	deliverySums  = "COUNT(*), SUM(d.gross_kg), SUM(d.tare_kg), SUM(d.net_kg)"
+)
+
+func NewDeliveryRepository(database config.DB) DeliveryRepository {
+	return &deliveryRepo{db: database}
+}
+
+// Create inserts d and sets its ID.
+func (r *deliveryRepo) Create(ctx context.Context, tx *sql.Tx, d *model.Delivery) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	return tx.QueryRowContext(ctx, `INSERT INTO deliveries (user_id, delivered_on, vehicle, material, gross_kg, tare_kg, net_kg, inspection_id, notes, operator)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		d.UserID, dateArg(r.db, &d.DeliveredOn), d.Vehicle, d.Material, d.GrossKg, d.TareKg, d.NetKg, d.InspectionID, nullString(d.Notes), d.Operator).Scan(&d.ID)
+}
+
+// CountByUser counts the deliveries recorded for userID.
+func (r *deliveryRepo) CountByUser(ctx context.Context, tx *sql.Tx, userID string) (int, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	var n int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM deliveries WHERE user_id = $1", userID).Scan(&n)
+	return n, err
+}
+
+// Reassign moves every delivery of from to to, e.g. when holders are merged.
+func (r *deliveryRepo) Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE deliveries SET user_id = $1 WHERE user_id = $2", to, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// List returns the deliveries matching f, newest first.
+func (r *deliveryRepo) List(ctx context.Context, f model.DeliveryFilter) ([]model.Delivery, error) {
+	w := r.where(f)
+	query := "SELECT " + deliveryColumns + deliveryJoins + w.sql() + " ORDER BY d.delivered_on DESC, d.id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT " + w.arg(f.Limit)
+	}
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Delivery{}
+	for rows.Next() {
+		var d model.Delivery
+		err := rows.Scan(&d.ID, &d.UserID, &d.Name, &d.DeliveredOn, &d.Vehicle, &d.Material, &d.GrossKg, &d.TareKg, &d.NetKg,
+			&d.InspectionID, &d.Grade, &d.Notes, &d.Operator, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// TotalsByHolder sums the deliveries matching f per holder, largest net weight first.
+func (r *deliveryRepo) TotalsByHolder(ctx context.Context, f model.DeliveryFilter) ([]model.DeliveryTotal, error) {
+	w := r.where(f)
+	rows, err := r.db.QueryContext(ctx, "SELECT d.user_id, COALESCE(u.name, ''), "+deliverySums+deliveryJoins+w.sql()+
+		" GROUP BY d.user_id, u.name ORDER BY SUM(d.net_kg) DESC, d.user_id", w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []model.DeliveryTotal{}
+	for rows.Next() {
+		var t model.DeliveryTotal
+		if err := rows.Scan(&t.Key, &t.Name, &t.Count, &t.GrossKg, &t.TareKg, &t.NetKg); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// TotalsByDay sums the deliveries matching f per day, oldest first, keyed
+// YYYY-MM-DD.
+func (r *deliveryRepo) TotalsByDay(ctx context.Context, f model.DeliveryFilter) ([]model.DeliveryTotal, error) {
+	w := r.where(f)
+	rows, err := r.db.QueryContext(ctx, "SELECT d.delivered_on, "+deliverySums+deliveryJoins+w.sql()+
+		" GROUP BY d.delivered_on ORDER BY d.delivered_on", w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []model.DeliveryTotal{}
+	for rows.Next() {
+		var t model.DeliveryTotal
+		var day time.Time
+		if err := rows.Scan(&day, &t.Count, &t.GrossKg, &t.TareKg, &t.NetKg); err != nil {
+			return nil, err
+		}
+		t.Key = day.Format(time.DateOnly)
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+func (r *deliveryRepo) where(f model.DeliveryFilter) *whereBuilder {
+	w := &whereBuilder{}
+	if f.UserID != "" {
+		w.add("d.user_id = ?", f.UserID)
+	}
+	if m := strings.TrimSpace(f.Material); m != "" {
+		w.add("LOWER(d.material) = ?", strings.ToLower(m))
+	}
+	if f.From != nil {
+		w.add("d.delivered_on >= ?", dateArg(r.db, f.From))
+	}
+	if f.To != nil {
+		w.add("d.delivered_on <= ?", dateArg(r.db, f.To))
+	}
+	return w
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"idcard/internal/config"
+	"idcard/internal/model"
+)
+
+type (
+	HolderTypeRepository interface {
+		List(ctx context.Context) ([]model.HolderType, error)
+		Get(ctx context.Context, code string) (*model.HolderType, error)
+		Create(ctx context.Context, t *model.HolderType) error
+		Update(ctx context.Context, t *model.HolderType) error
+		Delete(ctx context.Context, code string) error
+		CountUsers(ctx context.Context, code string) (int, error)
+	}
+	holderTypeRepo struct {
+		db config.DB
+	}
+)
+
+const holderTypeColumns = "code, label, id_prefix, card_template, pdf_form, back_template, created_at, updated_at"
+
+func NewHolderTypeRepository(database config.DB) HolderTypeRepository {
+	return &holderTypeRepo{db: database}
+}
+
+// List returns every holder type, oldest first so the original types lead.
+func (r *holderTypeRepo) List(ctx context.Context) ([]model.HolderType, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+holderTypeColumns+" FROM holder_types ORDER BY created_at, code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []model.HolderType{}
+	for rows.Next() {
+		t, err := scanHolderType(rows)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, *t)
+	}
+	return types, rows.Err()
+}
+
+func (r *holderTypeRepo) Get(ctx context.Context, code string) (*model.HolderType, error) {
+	return scanHolderType(r.db.QueryRowContext(ctx, "SELECT "+holderTypeColumns+" FROM holder_types WHERE code = $1", code))
+}
+
+func (r *holderTypeRepo) Create(ctx context.Context, t *model.HolderType) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO holder_types (code, label, id_prefix, card_template, pdf_form, back_template) VALUES ($1, $2, $3, $4, $5, $6)",
+		t.Code, t.Label, t.IDPrefix, t.CardTemplate, t.PDFForm, t.BackTemplate)
+	return err
+}
+
+// Update changes everything but the code. It returns sql.ErrNoRows when the
+// code does not exist.
+func (r *holderTypeRepo) Update(ctx context.Context, t *model.HolderType) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE holder_types SET label = $2, id_prefix = $3, card_template = $4, pdf_form = $5, back_template = $6, updated_at = CURRENT_TIMESTAMP WHERE code = $1",
+		t.Code, t.Label, t.IDPrefix, t.CardTemplate, t.PDFForm, t.BackTemplate)
+	return expectOne(res, err)
+}
+
+// Delete removes a holder type no holder uses. It returns sql.ErrNoRows when
+// the code does not exist or is still in use.
+func (r *holderTypeRepo) Delete(ctx context.Context, code string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM holder_types WHERE code = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE status = $1)", code)
+	return expectOne(res, err)
+}
+
+// CountUsers counts the holders of a type, including inactive ones.
+func (r *holderTypeRepo) CountUsers(ctx context.Context, code string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE status = $1", code).Scan(&n)
+	return n, err
+}
+
+func scanHolderType(row rowScanner) (*model.HolderType, error) {
+	var t model.HolderType
+	if err := row.Scan(&t.Code, &t.Label, &t.IDPrefix, &t.CardTemplate, &t.PDFForm, &t.BackTemplate, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"idcard/internal/config"
+	"idcard/internal/model"
+)
+
+type (
+	InspectionRepository interface {
+		Create(ctx context.Context, tx *sql.Tx, in *model.Inspection) error
+		ListByUser(ctx context.Context, userID string) ([]model.Inspection, error)
+		ListForUpdate(ctx context.Context, tx *sql.Tx, userID string) ([]model.Inspection, error)
+		Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error)
+		CountByUser(ctx context.Context, tx *sql.Tx, userID string) (int, error)
+	}
+	inspectionRepo struct {
+		db config.DB
+	}
+
+	queryer interface {
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	}
+)
+
+const inspectionColumns = "id, user_id, inspected_on, grade, moisture, contamination, inspector, COALESCE(notes, ''), created_at"
+
+func NewInspectionRepository(database config.DB) InspectionRepository {
+	return &inspectionRepo{db: database}
+}
+
+// Create inserts in and sets its ID.
+func (r *inspectionRepo) Create(ctx context.Context, tx *sql.Tx, in *model.Inspection) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	return tx.QueryRowContext(ctx, `INSERT INTO inspections (user_id, inspected_on, grade, moisture, contamination, inspector, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		in.UserID, dateArg(r.db, &in.InspectedOn), in.Grade, in.Moisture, in.Contamination, in.Inspector, nullString(in.Notes)).Scan(&in.ID)
+}
+
+// ListByUser returns the inspections of userID, newest first.
+func (r *inspectionRepo) ListByUser(ctx context.Context, userID string) ([]model.Inspection, error) {
+	return r.list(ctx, r.db, userID)
+}
+
+// ListForUpdate is ListByUser inside tx, so it sees inspections tx added.
+func (r *inspectionRepo) ListForUpdate(ctx context.Context, tx *sql.Tx, userID string) ([]model.Inspection, error) {
+	if tx == nil {
+		return nil, errors.New("transaction is nil")
+	}
+	return r.list(ctx, tx, userID)
+}
+
+// CountByUser counts the inspections recorded for userID.
+func (r *inspectionRepo) CountByUser(ctx context.Context, tx *sql.Tx, userID string) (int, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	var n int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM inspections WHERE user_id = $1", userID).Scan(&n)
+	return n, err
+}
+
+// Reassign moves every inspection of from to to, e.g. when holders are merged.
+func (r *inspectionRepo) Reassign(ctx context.Context, tx *sql.Tx, from, to string) (int64, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE inspections SET user_id = $1 WHERE user_id = $2", to, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *inspectionRepo) list(ctx context.Context, q queryer, userID string) ([]model.Inspection, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+inspectionColumns+" FROM inspections WHERE user_id = $1 ORDER BY inspected_on DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Inspection{}
+	for rows.Next() {
+		var in model.Inspection
+		err := rows.Scan(&in.ID, &in.UserID, &in.InspectedOn, &in.Grade, &in.Moisture, &in.Contamination, &in.Inspector, &in.Notes, &in.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, in)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// NextUserSeq atomically increments and returns the counter of scope. The
+// row stays locked until tx ends, so concurrent inserts never share a value.
+func (r *userRepo) NextUserSeq(ctx context.Context, tx *sql.Tx, scope string) (int, error) {
+	if tx == nil {
+		return 0, errors.New("transaction is nil")
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_id_sequences (scope, last_value) VALUES ($1, 0) ON CONFLICT (scope) DO NOTHING", scope); err != nil {
+		return 0, err
+	}
+
+	var n int
+	err := tx.QueryRowContext(ctx, "UPDATE user_id_sequences SET last_value = last_value + 1 WHERE scope = $1 RETURNING last_value", scope).Scan(&n)
+	return n, err
+}
+
+// BumpUserSeq raises the counter of scope to at least n, e.g. after importing
+// rows that already carry an ID.
+func (r *userRepo) BumpUserSeq(ctx context.Context, tx *sql.Tx, scope string, n int) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_id_sequences (scope, last_value) VALUES ($1, $2) ON CONFLICT (scope) DO NOTHING", scope, n); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "UPDATE user_id_sequences SET last_value = $2 WHERE scope = $1 AND last_value < $2", scope, n)
+	return err
+}
+
+// ReclaimExpiredReservation frees one lapsed reservation of scope and returns
+// its ID so the number is reused instead of leaving a gap. It returns
+// sql.ErrNoRows when nothing has expired.
+func (r *userRepo) ReclaimExpiredReservation(ctx context.Context, tx *sql.Tx, scope string, now time.Time) (string, error) {
+	if tx == nil {
+		return "", errors.New("transaction is nil")
+	}
+	var id string
+	// expires_at is checked again outside the subquery so a row claimed by a
+	// concurrent transaction is skipped once its lock is released
+	err := tx.QueryRowContext(ctx, `DELETE FROM user_id_reservations
+		WHERE id = (SELECT id FROM user_id_reservations WHERE scope = $1 AND expires_at < $2 ORDER BY id LIMIT 1)
+		AND expires_at < $2
+		RETURNING id`, scope, now.UTC()).Scan(&id)
+	return id, err
+}
+
+func (r *userRepo) CreateReservation(ctx context.Context, tx *sql.Tx, id, scope, token string, expiresAt time.Time) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO user_id_reservations (id, scope, token, expires_at) VALUES ($1, $2, $3, $4)", id, scope, token, expiresAt.UTC())
+	return err
+}
+
+// TakeReservation consumes the reservation of id in scope when token matches
+// and it has not expired yet.
+func (r *userRepo) TakeReservation(ctx context.Context, tx *sql.Tx, id, scope, token string, now time.Time) (bool, error) {
+	if tx == nil {
+		return false, errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM user_id_reservations WHERE id = $1 AND scope = $2 AND token = $3 AND expires_at >= $4", id, scope, token, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"idcard/internal/config"
 	"idcard/internal/model"
+	"idcard/internal/util"
 	"log"
 	"time"
 )
@@ -13,18 +14,41 @@ import (
 type (
 	UserRepository interface {
 		Begin(ctx context.Context) (*sql.Tx, error)
-		Create(ctx context.Context, user *model.User) error
-		GetList(ctx context.Context, limit uint8) (*[]model.User, error)
-		GetLastUserId(ctx context.Context, status string) (string, error)
+		Create(ctx context.Context, tx *sql.Tx, user *model.User) error
+		GetList(ctx context.Context, f model.UserFilter) (*model.UserPage, error)
+		SearchByName(ctx context.Context, f model.UserFilter) (*model.NameMatchPage, error)
+		Demographics(ctx context.Context) ([]model.DemographicsRow, error)
+		ListPhones(ctx context.Context) (map[string]string, error)
+		UpdatePhone(ctx context.Context, tx *sql.Tx, id, phone string) error
+		ListUnmerged(ctx context.Context) ([]model.User, error)
+		Merge(ctx context.Context, tx *sql.Tx, id, into, reason string, at time.Time) error
+		Renew(ctx context.Context, tx *sql.Tx, id string, issuedAt, validUntil time.Time, issue int) error
+		SetState(ctx context.Context, tx *sql.Tx, id, state, reason string, from time.Time, until *time.Time) error
+		SetRating(ctx context.Context, tx *sql.Tx, id string, rating int, at time.Time) error
 		GetUserByNik(ctx context.Context, nik string) (user *model.User, err error)
-		UpdateUser(ctx context.Context, u *model.User) error
+		GetUserByID(ctx context.Context, id string) (*model.User, error)
+		GetUserForUpdate(ctx context.Context, tx *sql.Tx, id string) (*model.User, error)
+		UpdateUser(ctx context.Context, tx *sql.Tx, u *model.User) error
 		UpsertUser(ctx context.Context, tx *sql.Tx, u model.User) (int64, error)
+		Deactivate(ctx context.Context, tx *sql.Tx, id, reason string, at time.Time) error
+		Restore(ctx context.Context, tx *sql.Tx, id string) error
+		Delete(ctx context.Context, tx *sql.Tx, id string) error
+
+		NextUserSeq(ctx context.Context, tx *sql.Tx, scope string) (int, error)
+		BumpUserSeq(ctx context.Context, tx *sql.Tx, scope string, n int) error
+		ReclaimExpiredReservation(ctx context.Context, tx *sql.Tx, scope string, now time.Time) (string, error)
+		CreateReservation(ctx context.Context, tx *sql.Tx, id, scope, token string, expiresAt time.Time) error
+		TakeReservation(ctx context.Context, tx *sql.Tx, id, scope, token string, now time.Time) (bool, error)
 	}
 	userRepo struct {
 		db config.DB
 	}
 )
 
+const userColumns = "id, nik, status, name, phone, address, rating, COALESCE(notes, ''), photo, created_at, updated_at, active, deleted_at, COALESCE(delete_reason, ''), " +
+	"COALESCE(province_code, ''), COALESCE(regency_code, ''), COALESCE(district_code, ''), birth_date, COALESCE(gender, ''), COALESCE(merged_into, ''), issued_at, valid_until, " +
+	"state, COALESCE(state_reason, ''), state_from, state_until, rated_at, card_issue"
+
 func NewUserRepository(database config.DB) UserRepository {
 	return &userRepo{db: database}
 }
@@ -33,57 +57,51 @@ func (r *userRepo) Begin(ctx context.Context) (*sql.Tx, error) {
 	return r.db.BeginTx(ctx, nil)
 }
 
-func (r *userRepo) Create(ctx context.Context, u *model.User) error {
-	query := `INSERT INTO users (id, nik, status, name, phone, address, rating, notes, photo) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+func (r *userRepo) Create(ctx context.Context, tx *sql.Tx, u *model.User) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	query := `INSERT INTO users (id, nik, status, name, phone, address, rating, notes, photo, name_key,
+			province_code, regency_code, district_code, birth_date, gender, issued_at, valid_until, card_issue)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
 
-	_, err := r.db.ExecContext(ctx, query, u.ID, u.NIK, u.Status, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo)
+	_, err := tx.ExecContext(ctx, query, u.ID, u.NIK, u.Status, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo, util.NameKey(u.Name),
+		nullString(u.ProvinceCode), nullString(u.RegencyCode), nullString(u.DistrictCode), r.dateArg(u.BirthDate), nullString(u.Gender),
+		r.timePtrArg(u.IssuedAt), r.dateArg(u.ValidUntil), u.CardIssue)
 
 	return err
 }
 
-func (r *userRepo) GetList(ctx context.Context, limit uint8) (*[]model.User, error) {
-	users := []model.User{}
-	rows, err := r.db.Query("SELECT id, nik, status, name, phone, address, rating, notes, photo, created_at, updated_at FROM users ORDER BY updated_at DESC LIMIT $1", limit)
-
-	if err != nil {
-		log.Println("GetList error:", err)
-		return nil, err
-	}
-
-	defer rows.Close()
-	for rows.Next() {
-		var u model.User
-		err := rows.Scan(&u.ID, &u.NIK, &u.Status, &u.Name, &u.Phone, &u.Address, &u.Rating, &u.Notes, &u.Photo, &u.CreatedAt, &u.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, u)
-	}
-
-	return &users, nil
+func (r *userRepo) GetUserByNik(ctx context.Context, nik string) (user *model.User, err error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE nik = $1", nik))
 }
 
-func (r *userRepo) GetLastUserId(ctx context.Context, status string) (string, error) {
-	var (
-		uID string
-		tgl time.Time
-	)
-
-	err := r.db.QueryRow("SELECT ID, created_at FROM users where status=$1 ORDER BY created_at DESC LIMIT 1", status).Scan(&uID, &tgl)
-
-	return uID, err
+func (r *userRepo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
 }
 
-func (r *userRepo) GetUserByNik(ctx context.Context, nik string) (user *model.User, err error) {
-	var u model.User
-	err = r.db.QueryRow("SELECT * FROM users WHERE nik = $1", nik).Scan(&u.ID, &u.NIK, &u.Status, &u.Name, &u.Phone, &u.Address, &u.Rating, &u.Notes, &u.Photo, &u.CreatedAt, &u.UpdatedAt)
-
-	return &u, err
+// GetUserForUpdate reads id inside tx, locking the row on Postgres so the
+// caller sees the state its own update will replace.
+func (r *userRepo) GetUserForUpdate(ctx context.Context, tx *sql.Tx, id string) (*model.User, error) {
+	if tx == nil {
+		return nil, errors.New("transaction is nil")
+	}
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1"
+	if r.db.Driver() == config.DriverPostgres {
+		query += " FOR UPDATE"
+	}
+	return scanUser(tx.QueryRowContext(ctx, query, id))
 }
 
-func (r *userRepo) UpdateUser(ctx context.Context, u *model.User) error {
-	_, err := r.db.Exec("UPDATE users SET nik=$1, status=$2, name=$3, phone=$4, address=$5, rating=$6, notes=$7, photo=$8 WHERE users.id=$9",
-		u.NIK, u.Status, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo, u.ID)
+func (r *userRepo) UpdateUser(ctx context.Context, tx *sql.Tx, u *model.User) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE users SET nik=$1, status=$2, name=$3, phone=$4, address=$5, rating=$6, notes=$7, photo=$8, name_key=$10,
+			province_code=$11, regency_code=$12, district_code=$13, birth_date=$14, gender=$15, updated_at=CURRENT_TIMESTAMP
+		WHERE users.id=$9`,
+		u.NIK, u.Status, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo, u.ID, util.NameKey(u.Name),
+		nullString(u.ProvinceCode), nullString(u.RegencyCode), nullString(u.DistrictCode), r.dateArg(u.BirthDate), nullString(u.Gender))
 	return err
 }
 
@@ -91,8 +109,9 @@ func (r *userRepo) UpsertUser(ctx context.Context, tx *sql.Tx, u model.User) (in
 	if tx == nil {
 		return 0, errors.New("transaction is nil")
 	}
-	res, err := tx.ExecContext(ctx, `INSERT INTO users (id, status, nik, name, phone, address, rating, notes, photo)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	res, err := tx.ExecContext(ctx, `INSERT INTO users (id, status, nik, name, phone, address, rating, notes, photo, name_key,
+				province_code, regency_code, district_code, birth_date, gender, issued_at, valid_until, card_issue)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 			ON CONFLICT (id) DO UPDATE SET 
 			name = EXCLUDED.name,
 			nik =  EXCLUDED.nik,
@@ -100,7 +119,16 @@ func (r *userRepo) UpsertUser(ctx context.Context, tx *sql.Tx, u model.User) (in
 			address = EXCLUDED.address,
 			rating = EXCLUDED.rating,
 			notes = EXCLUDED.notes,
-			photo = EXCLUDED.photo`, u.ID, u.Status, u.NIK, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo)
+			photo = EXCLUDED.photo,
+			name_key = EXCLUDED.name_key,
+			province_code = EXCLUDED.province_code,
+			regency_code = EXCLUDED.regency_code,
+			district_code = EXCLUDED.district_code,
+			birth_date = EXCLUDED.birth_date,
+			gender = EXCLUDED.gender,
+			updated_at = CURRENT_TIMESTAMP`, u.ID, u.Status, u.NIK, u.Name, u.Phone, u.Address, u.Rating, u.Notes, u.Photo, util.NameKey(u.Name),
+		nullString(u.ProvinceCode), nullString(u.RegencyCode), nullString(u.DistrictCode), r.dateArg(u.BirthDate), nullString(u.Gender),
+		r.timePtrArg(u.IssuedAt), r.dateArg(u.ValidUntil), u.CardIssue)
 	if err != nil {
 		log.Println("Error during ExecContext:", err)
 		return 0, err
@@ -108,3 +136,187 @@ func (r *userRepo) UpsertUser(ctx context.Context, tx *sql.Tx, u model.User) (in
 	affected, _ := res.RowsAffected()
 	return affected, err
 }
+
+// ListPhones returns the phone of every holder by ID.
+func (r *userRepo) ListPhones(ctx context.Context) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, phone FROM users")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	phones := map[string]string{}
+	for rows.Next() {
+		var id, phone string
+		if err := rows.Scan(&id, &phone); err != nil {
+			return nil, err
+		}
+		phones[id] = phone
+	}
+	return phones, rows.Err()
+}
+
+func (r *userRepo) UpdatePhone(ctx context.Context, tx *sql.Tx, id, phone string) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET phone = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", phone, id)
+	return expectOne(res, err)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads one row selected with userColumns, followed by any extra
+// columns into extra.
+func scanUser(row rowScanner, extra ...any) (*model.User, error) {
+	var u model.User
+	dest := []any{&u.ID, &u.NIK, &u.Status, &u.Name, &u.Phone, &u.Address, &u.Rating, &u.Notes, &u.Photo, &u.CreatedAt, &u.UpdatedAt,
+		&u.Active, &u.DeletedAt, &u.DeleteReason, &u.ProvinceCode, &u.RegencyCode, &u.DistrictCode, &u.BirthDate, &u.Gender, &u.MergedInto,
+		&u.IssuedAt, &u.ValidUntil, &u.State, &u.StateReason, &u.StateFrom, &u.StateUntil, &u.RatedAt, &u.CardIssue}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Deactivate soft deletes an active holder. It returns sql.ErrNoRows when id
+// does not exist or is already inactive.
+func (r *userRepo) Deactivate(ctx context.Context, tx *sql.Tx, id, reason string, at time.Time) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET active = FALSE, deleted_at = $2, delete_reason = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND active = TRUE",
+		id, at.UTC(), reason)
+	return expectOne(res, err)
+}
+
+// Restore reactivates a soft deleted holder. It returns sql.ErrNoRows when
+// id does not exist, is already active or was merged into another holder.
+func (r *userRepo) Restore(ctx context.Context, tx *sql.Tx, id string) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET active = TRUE, deleted_at = NULL, delete_reason = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND active = FALSE AND merged_into IS NULL", id)
+	return expectOne(res, err)
+}
+
+// Merge retires id as a duplicate of into. It returns sql.ErrNoRows when id
+// does not exist or was already merged.
+func (r *userRepo) Merge(ctx context.Context, tx *sql.Tx, id, into, reason string, at time.Time) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE users SET active = FALSE, deleted_at = COALESCE(deleted_at, $3), delete_reason = $4, merged_into = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND merged_into IS NULL`, id, into, at.UTC(), reason)
+	return expectOne(res, err)
+}
+
+// Renew records card issue number issue, valid through validUntil. It returns
+// sql.ErrNoRows when id does not exist.
+func (r *userRepo) Renew(ctx context.Context, tx *sql.Tx, id string, issuedAt, validUntil time.Time, issue int) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET issued_at = $2, valid_until = $3, card_issue = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		id, r.timeArg(issuedAt), r.dateArg(&validUntil), issue)
+	return expectOne(res, err)
+}
+
+// SetState records the lifecycle state of id. It returns sql.ErrNoRows when
+// id does not exist.
+func (r *userRepo) SetState(ctx context.Context, tx *sql.Tx, id, state, reason string, from time.Time, until *time.Time) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE users SET state = $2, state_reason = $3, state_from = $4, state_until = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, state, nullString(reason), r.dateArg(&from), r.dateArg(until))
+	return expectOne(res, err)
+}
+
+// SetRating stores a rating computed from QC inspections. It returns
+// sql.ErrNoRows when id does not exist.
+func (r *userRepo) SetRating(ctx context.Context, tx *sql.Tx, id string, rating int, at time.Time) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE users SET rating = $2, rated_at = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		id, rating, r.timeArg(at))
+	return expectOne(res, err)
+}
+
+// ListUnmerged returns every holder not merged away, by ID.
+func (r *userRepo) ListUnmerged(ctx context.Context) ([]model.User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE merged_into IS NULL ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// Delete removes the row of id for good.
+func (r *userRepo) Delete(ctx context.Context, tx *sql.Tx, id string) error {
+	if tx == nil {
+		return errors.New("transaction is nil")
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	return expectOne(res, err)
+}
+
+// dateArg binds a calendar date; SQLite keeps dates as "YYYY-MM-DD" text so
+// they compare as strings.
+func (r *userRepo) dateArg(t *time.Time) any {
+	return dateArg(r.db, t)
+}
+
+func dateArg(db config.DB, t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	if db.Driver() == config.DriverSQLite {
+		return t.Format(time.DateOnly)
+	}
+	return *t
+}
+
+func (r *userRepo) timePtrArg(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return r.timeArg(*t)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// expectOne turns an update that touched no row into sql.ErrNoRows.
+func expectOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"idcard/internal/config"
+	"idcard/internal/migrate"
+	"idcard/internal/model"
+)
+
+// newTestDB opens the migrated in-memory SQLite database shared by the
+// tests of this package.
+func newTestDB(t *testing.T) config.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", config.DriverSQLite)
+	t.Setenv("SQLITE_PATH", ":memory:")
+	db, err := config.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate.Up(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func seedUser(t *testing.T, repo UserRepository, id, nik string) *model.User {
+	t.Helper()
+	ctx := context.Background()
+	u := &model.User{ID: id, NIK: nik, Status: "S", Name: "Budi Santoso", Phone: "+6281234567890",
+		Address: "Jl Mawar 1", Photo: "uploads/" + id + ".png", Active: true, State: model.StateActive, CardIssue: 1}
+	tx, err := repo.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := repo.Create(ctx, tx, u); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func cancelled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestUserRepoHonorsCancellation(t *testing.T) {
+	repo := NewUserRepository(newTestDB(t))
+	u := seedUser(t, repo, "S901", "3374015708900901")
+
+	// The same calls succeed with a live context, so the failures below
+	// come from the cancellation alone
+	if _, err := repo.GetUserByID(context.Background(), u.ID); err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"GetList", func(ctx context.Context) error {
+			_, err := repo.GetList(ctx, model.UserFilter{Limit: 10})
+			return err
+		}},
+		{"GetUserByID", func(ctx context.Context) error {
+			_, err := repo.GetUserByID(ctx, u.ID)
+			return err
+		}},
+		{"GetUserByNik", func(ctx context.Context) error {
+			_, err := repo.GetUserByNik(ctx, u.NIK)
+			return err
+		}},
+		{"UpdateUser", func(ctx context.Context) error {
+			tx, err := repo.Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			return repo.UpdateUser(ctx, tx, u)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(cancelled()); !errors.Is(err, context.Canceled) {
+				t.Fatalf("got %v, want context.Canceled", err)
+			}
+		})
+	}
+}
+
+func TestUserRepoHonorsDeadline(t *testing.T) {
+	repo := NewUserRepository(newTestDB(t))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := repo.GetList(ctx, model.UserFilter{Limit: 10}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestSearchByNameAppliesFiltersAndCursor(t *testing.T) {
+	repo := NewUserRepository(newTestDB(t))
+	ctx := context.Background()
+	for _, u := range []model.User{
+		{ID: "S911", NIK: "3374015708900911", Status: "S", Name: "Slamet Riyadi"},
+		{ID: "S912", NIK: "3374015708900912", Status: "S", Name: "Slamet Riyadhi"},
+		{ID: "V913", NIK: "3374015708900913", Status: "V", Name: "Slamet Riyadi"},
+	} {
+		u.Phone, u.Address, u.Photo = "+6281234567890", "Jl Mawar 1", "uploads/"+u.ID+".png"
+		u.Active, u.State, u.CardIssue = true, model.StateActive, 1
+		tx, err := repo.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Create(ctx, tx, &u); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := model.UserFilter{Query: "slamet riyadi", Status: "S", Limit: 1}
+	var ids []string
+	for range 3 {
+		page, err := repo.SearchByName(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range page.Matches {
+			ids = append(ids, m.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		f.Cursor = page.NextCursor
+	}
+	if len(ids) != 2 || ids[0] != "S911" || ids[1] != "S912" {
+		t.Fatalf("got %v, want [S911 S912]", ids)
+	}
+
+	f.Cursor = encodeCursor(listCursor{Sort: SortName, Value: "x"})
+	if _, err := repo.SearchByName(ctx, f); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("got %v, want ErrInvalidCursor", err)
+	}
+}
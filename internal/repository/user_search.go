@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/util"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortUpdated = "updated_at"
+	SortCreated = "created_at"
+	SortName    = "name"
+	SortID      = "id"
+	SortRating  = "rating"
+
+	DefaultListLimit = 12
+	MaxListLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+type (
+	// listCursor is the position after the last row of a page: its sort
+	// value and ID, so rows sharing a sort value are neither skipped nor repeated.
+	listCursor struct {
+		Sort  string `json:"s"`
+		Desc  bool   `json:"d"`
+		Value string `json:"v"`
+		ID    string `json:"id"`
+	}
+
+	// whereBuilder collects AND-ed conditions with Postgres-style placeholders.
+	whereBuilder struct {
+		conds []string
+		args  []any
+	}
+)
+
+func (w *whereBuilder) add(cond string, args ...any) {
+	for _, a := range args {
+		w.args = append(w.args, a)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+func (w *whereBuilder) arg(a any) string {
+	w.args = append(w.args, a)
+	return fmt.Sprintf("$%d", len(w.args))
+}
+
+func (w *whereBuilder) sql() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// GetList returns one page of holders matching f, ordered by f.Sort with id
+// as tie breaker.
+func (r *userRepo) GetList(ctx context.Context, f model.UserFilter) (*model.UserPage, error) {
+	sortCol := f.Sort
+	switch sortCol {
+	case "":
+		sortCol = SortUpdated
+	case SortUpdated, SortCreated, SortName, SortID, SortRating:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSort, f.Sort)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+
+	w := r.filterWhere(f)
+	if q := strings.TrimSpace(f.Query); q != "" {
+		like := "%" + escapeLike(strings.ToLower(q)) + "%"
+		// Phones are stored as +628..., people type 0812...
+		phoneLike := like
+		if key := util.PhoneSearchKey(q); key != "" {
+			phoneLike = "%" + escapeLike(key) + "%"
+		}
+		w.add(`(LOWER(name) LIKE ? ESCAPE '\' OR nik LIKE ? ESCAPE '\' OR phone LIKE ? ESCAPE '\' OR LOWER(id) LIKE ? ESCAPE '\' OR LOWER(status) = ?)`,
+			like, like, phoneLike, like, strings.ToLower(q))
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil || c.Sort != sortCol || c.Desc != f.Desc {
+			return nil, ErrInvalidCursor
+		}
+		v, err := r.cursorArg(sortCol, c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+		w.add(fmt.Sprintf("(%s, id) %s (?, ?)", sortCol, op), v, c.ID)
+	}
+
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf("SELECT %s FROM users%s ORDER BY %s %s, id %s LIMIT %s",
+		userColumns, w.sql(), sortCol, dir, dir, w.arg(limit+1))
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &model.UserPage{Users: []model.User{}}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, *u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		last := page.Users[limit-1]
+		page.NextCursor = encodeCursor(listCursor{Sort: sortCol, Desc: f.Desc, Value: sortValue(sortCol, &last), ID: last.ID})
+	}
+	return page, nil
+}
+
+// filterWhere holds the constraints of f other than the free-text query
+// and the cursor, which each kind of search applies its own way.
+func (r *userRepo) filterWhere(f model.UserFilter) *whereBuilder {
+	w := &whereBuilder{}
+	w.add("active = ?", !f.Inactive)
+	if f.Status != "" {
+		w.add("status = ?", f.Status)
+	}
+	if f.MinRating != nil {
+		w.add("rating >= ?", *f.MinRating)
+	}
+	if f.MaxRating != nil {
+		w.add("rating <= ?", *f.MaxRating)
+	}
+	if f.CreatedFrom != nil {
+		w.add("created_at >= ?", r.timeArg(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		w.add("created_at < ?", r.timeArg(*f.CreatedTo))
+	}
+
+	if f.ValidUntilFrom != nil {
+		w.add("valid_until >= ?", r.dateArg(f.ValidUntilFrom))
+	}
+	if f.ValidUntilTo != nil {
+		w.add("valid_until < ?", r.dateArg(f.ValidUntilTo))
+	}
+
+	// A suspension lapses after state_until, so it counts as active again
+	today := time.Now()
+	switch f.State {
+	case "":
+	case model.StateActive:
+		w.add("(state = ? OR (state = ? AND state_until < ?))", model.StateActive, model.StateSuspended, r.dateArg(&today))
+	case model.StateSuspended:
+		w.add("state = ? AND (state_until IS NULL OR state_until >= ?)", model.StateSuspended, r.dateArg(&today))
+	default:
+		w.add("state = ?", f.State)
+	}
+
+	if f.Province != "" {
+		w.add("province_code = ?", f.Province)
+	}
+	if f.Regency != "" {
+		w.add("regency_code = ?", f.Regency)
+	}
+	if f.Gender != "" {
+		w.add("gender = ?", f.Gender)
+	}
+	// Ages are whole years today: MinAge a means born at least a years ago,
+	// MaxAge b means born less than b+1 years ago
+	now := time.Now().UTC()
+	if f.MinAge != nil {
+		born := now.AddDate(-*f.MinAge, 0, 0)
+		w.add("birth_date <= ?", r.dateArg(&born))
+	}
+	if f.MaxAge != nil {
+		born := now.AddDate(-*f.MaxAge-1, 0, 0)
+		w.add("birth_date > ?", r.dateArg(&born))
+	}
+	return w
+}
+
+// timeArg binds t so it compares correctly with CURRENT_TIMESTAMP defaults,
+// which SQLite stores as "YYYY-MM-DD HH:MM:SS" text.
+func (r *userRepo) timeArg(t time.Time) any {
+	if r.db.Driver() == config.DriverSQLite {
+		return t.UTC().Format(time.DateTime)
+	}
+	return t.UTC()
+}
+
+func (r *userRepo) cursorArg(sortCol, value string) (any, error) {
+	switch sortCol {
+	case SortUpdated, SortCreated:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		return r.timeArg(t), nil
+	case SortRating:
+		var n int
+		_, err := fmt.Sscan(value, &n)
+		return n, err
+	default:
+		return value, nil
+	}
+}
+
+func sortValue(sortCol string, u *model.User) string {
+	switch sortCol {
+	case SortUpdated:
+		return u.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case SortCreated:
+		return u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortName:
+		return u.Name
+	case SortRating:
+		return fmt.Sprint(u.Rating)
+	default:
+		return u.ID
+	}
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(b, &c)
+}
+
+// escapeLike makes % and _ in user input match literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// nameMatchThreshold is the lowest score returned by SearchByName. It mirrors
+// the pg_trgm default similarity threshold.
+const nameMatchThreshold = 0.3
+
+// sortScore marks the cursor of a SearchByName page. Ranked results have no
+// stable sort key, so the cursor holds how many matches came before.
+const sortScore = "score"
+
+// SearchByName ranks the holders matching the constraints of f by how
+// closely their name matches f.Query, tolerating typos and spelling
+// variants. f.Sort is ignored. Postgres uses the pg_trgm index; SQLite
+// scores every candidate in Go, which is fine at its scale.
+func (r *userRepo) SearchByName(ctx context.Context, f model.UserFilter) (*model.NameMatchPage, error) {
+	page := &model.NameMatchPage{Matches: []model.NameMatch{}}
+	key := util.NameKey(f.Query)
+	if key == "" {
+		return page, nil
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+	offset := 0
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil || c.Sort != sortScore {
+			return nil, ErrInvalidCursor
+		}
+		if offset, err = strconv.Atoi(c.Value); err != nil || offset < 0 {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	w := r.filterWhere(f)
+	var matches []model.NameMatch
+	var err error
+	if r.db.Driver() == config.DriverSQLite {
+		matches, err = r.searchByNameScan(ctx, w, key, offset, limit+1)
+	} else {
+		matches, err = r.searchByNameTrgm(ctx, w, key, offset, limit+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+		page.NextCursor = encodeCursor(listCursor{Sort: sortScore, Value: strconv.Itoa(offset + limit)})
+	}
+	page.Matches = matches
+	return page, nil
+}
+
+func (r *userRepo) searchByNameTrgm(ctx context.Context, w *whereBuilder, key string, offset, limit int) ([]model.NameMatch, error) {
+	k := w.arg(key)
+	w.add("(name_key % " + k + " OR " + k + " <% name_key)")
+	query := fmt.Sprintf(`SELECT %s,
+			GREATEST(similarity(name_key, %s), word_similarity(%s, name_key)) AS score
+		FROM users%s
+		ORDER BY score DESC, name_key, id
+		LIMIT %s OFFSET %s`, userColumns, k, k, w.sql(), w.arg(limit), w.arg(offset))
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []model.NameMatch{}
+	for rows.Next() {
+		var score float64
+		u, err := scanUser(rows, &score)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, model.NameMatch{User: *u, Score: score})
+	}
+	return res, rows.Err()
+}
+
+func (r *userRepo) searchByNameScan(ctx context.Context, w *whereBuilder, key string, offset, limit int) ([]model.NameMatch, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+userColumns+", name_key FROM users"+w.sql(), w.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type hit struct {
+		model.NameMatch
+		key string
+	}
+	hits := []hit{}
+	for rows.Next() {
+		var nameKey string
+		u, err := scanUser(rows, &nameKey)
+		if err != nil {
+			return nil, err
+		}
+		if score := util.Similarity(key, nameKey); score >= nameMatchThreshold {
+			hits = append(hits, hit{model.NameMatch{User: *u, Score: score}, nameKey})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].key != hits[j].key {
+			return hits[i].key < hits[j].key
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	hits = hits[min(offset, len(hits)):]
+	res := make([]model.NameMatch, 0, min(limit, len(hits)))
+	for _, h := range hits[:min(limit, len(hits))] {
+		res = append(res, h.NameMatch)
+	}
+	return res, nil
+}
+
+// Demographics counts active holders per region, gender and birth date.
+// Holders without a decoded NIK are grouped under empty values.
+func (r *userRepo) Demographics(ctx context.Context) ([]model.DemographicsRow, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT COALESCE(province_code, ''), COALESCE(regency_code, ''), COALESCE(gender, ''), birth_date, COUNT(*)
+		FROM users WHERE active = TRUE
+		GROUP BY province_code, regency_code, gender, birth_date`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []model.DemographicsRow{}
+	for rows.Next() {
+		var d model.DemographicsRow
+		if err := rows.Scan(&d.ProvinceCode, &d.RegencyCode, &d.Gender, &d.BirthDate, &d.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"idcard/internal/model"
+	"time"
+)
+
+type actorKey struct{}
+
+// WithActor attaches the operator and source of a request to ctx so the
+// audit trail can record who made each change.
+func WithActor(ctx context.Context, a model.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+func actorFrom(ctx context.Context) model.Actor {
+	a, _ := ctx.Value(actorKey{}).(model.Actor)
+	if a.Operator == "" {
+		a.Operator = "unknown"
+	}
+	if a.Source == "" {
+		a.Source = model.SourceAPI
+	}
+	return a
+}
+
+// auditFields is the audited snapshot of u; nil yields no fields.
+func auditFields(u *model.User) map[string]any {
+	if u == nil {
+		return map[string]any{}
+	}
+	return map[string]any{
+		"nik":           u.NIK,
+		"status":        u.Status,
+		"name":          u.Name,
+		"phone":         u.Phone,
+		"address":       u.Address,
+		"rating":        u.Rating,
+		"notes":         u.Notes,
+		"photo":         u.Photo,
+		"active":        u.Active,
+		"delete_reason": u.DeleteReason,
+		"merged_into":   u.MergedInto,
+		"valid_until":   formatDate(u.ValidUntil),
+		"card_issue":    u.CardIssue,
+		"state":         u.State,
+		"state_reason":  u.StateReason,
+		"state_from":    formatDate(u.StateFrom),
+		"state_until":   formatDate(u.StateUntil),
+	}
+}
+
+// diffUser lists the fields that differ between before and after. Either
+// side may be nil for creations and deletions.
+func diffUser(before, after *model.User) map[string]model.FieldChange {
+	b, a := auditFields(before), auditFields(after)
+	changes := map[string]model.FieldChange{}
+	for k, av := range a {
+		if bv, ok := b[k]; !ok || bv != av {
+			changes[k] = model.FieldChange{Before: b[k], After: av}
+		}
+	}
+	for k, bv := range b {
+		if _, ok := a[k]; !ok {
+			changes[k] = model.FieldChange{Before: bv}
+		}
+	}
+	return changes
+}
+
+// formatDate renders an optional date for the audit trail; pointers would
+// compare by identity.
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"idcard/internal/util"
+	"io"
+	"strings"
+	"time"
+)
+
+type (
+	DeliveryService interface {
+		AddDelivery(ctx context.Context, cardNo string, d *model.Delivery, qc *model.Inspection) error
+		GetDeliveries(ctx context.Context, f model.DeliveryFilter, period string) (*model.DeliveryReport, error)
+		ExportDeliveries(ctx context.Context, f model.DeliveryFilter, period string, out io.Writer) error
+	}
+	deliveryServ struct {
+		repo     repository.DeliveryRepository
+		users    repository.UserRepository
+		qc       *inspectionServ
+		ids      *config.IDScheme
+		excelSvc ExcelService
+	}
+)
+
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// deliveryLogLimit caps the deliveries listed on screen; totals and exports
+// cover every matching delivery.
+const deliveryLogLimit = 500
+
+func NewDeliveryService(repo repository.DeliveryRepository, users repository.UserRepository, inspections repository.InspectionRepository, audit repository.AuditRepository,
+	formula *config.RatingFormula, ids *config.IDScheme, excel ExcelService) DeliveryService {
+	qc := &inspectionServ{repo: inspections, users: users, audit: audit, formula: formula}
+	return &deliveryServ{repo: repo, users: users, qc: qc, ids: ids, excelSvc: excel}
+}
+
+// AddDelivery records a delivery by the holder of card cardNo, with the QC
+// inspection of the load when qc is not nil. Holders who may not enter are
+// refused.
+func (s *deliveryServ) AddDelivery(ctx context.Context, cardNo string, d *model.Delivery, qc *model.Inspection) error {
+	d.UserID = s.ids.ParseCardNo(cardNo)
+	d.Vehicle = strings.ToUpper(strings.Join(strings.Fields(d.Vehicle), " "))
+	d.Material = strings.TrimSpace(d.Material)
+	d.Operator = actorFrom(ctx).Operator
+	if err := validateDelivery(d); err != nil {
+		return err
+	}
+	if qc != nil {
+		qc.UserID, qc.InspectedOn = d.UserID, d.DeliveredOn
+		if err := s.qc.validate(qc); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.users.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	u, err := s.users.GetUserForUpdate(ctx, tx, d.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("kartu %s tidak terdaftar", s.ids.Display(d.UserID))
+	}
+	if err != nil {
+		return err
+	}
+	if err := admissible(u, s.ids.Display(u.ID), dateOf(time.Now())); err != nil {
+		return err
+	}
+
+	if qc != nil {
+		if _, err := s.qc.record(ctx, tx, u, qc); err != nil {
+			return err
+		}
+		d.InspectionID = &qc.ID
+	}
+	if err := s.repo.Create(ctx, tx, d); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDeliveries lists the deliveries matching f with totals per holder and
+// per period (PeriodDay or PeriodMonth).
+func (s *deliveryServ) GetDeliveries(ctx context.Context, f model.DeliveryFilter, period string) (*model.DeliveryReport, error) {
+	if f.Limit == 0 {
+		f.Limit = deliveryLogLimit
+	}
+	return s.report(ctx, f, period)
+}
+
+// ExportDeliveries writes every delivery matching f and its totals as XLSX.
+func (s *deliveryServ) ExportDeliveries(ctx context.Context, f model.DeliveryFilter, period string, out io.Writer) error {
+	f.Limit = 0
+	report, err := s.report(ctx, f, period)
+	if err != nil {
+		return err
+	}
+	return s.excelSvc.WriteDeliveries(report, out)
+}
+
+func (s *deliveryServ) report(ctx context.Context, f model.DeliveryFilter, period string) (*model.DeliveryReport, error) {
+	if period == "" {
+		period = model.PeriodDay
+	}
+	if period != model.PeriodDay && period != model.PeriodMonth {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPeriod, period)
+	}
+	if f.UserID != "" {
+		f.UserID = s.ids.ParseCardNo(f.UserID)
+	}
+
+	deliveries, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	byHolder, err := s.repo.TotalsByHolder(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	byDay, err := s.repo.TotalsByDay(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.DeliveryReport{Deliveries: deliveries, ByHolder: byHolder, ByPeriod: byDay, Total: model.DeliveryTotal{Key: "total"}}
+	if period == model.PeriodMonth {
+		report.ByPeriod = rollUpMonths(byDay)
+	}
+	for _, t := range byDay {
+		addTotal(&report.Total, t)
+	}
+	return report, nil
+}
+
+func validateDelivery(d *model.Delivery) error {
+	switch {
+	case d.UserID == "":
+		return errors.New("nomor kartu masih kosong")
+	case d.Vehicle == "":
+		return errors.New("nomor kendaraan masih kosong")
+	case d.Material == "":
+		return errors.New("jenis material masih kosong")
+	case d.GrossKg <= 0 || d.TareKg < 0:
+		return errors.New("berat bruto harus lebih dari 0 dan tara tidak boleh negatif")
+	case d.TareKg >= d.GrossKg:
+		return errors.New("berat tara harus lebih kecil dari bruto")
+	}
+	today := dateOf(time.Now())
+	if d.DeliveredOn.IsZero() {
+		d.DeliveredOn = today
+	}
+	if d.DeliveredOn.After(today) {
+		return fmt.Errorf("delivery date %s is in the future", d.DeliveredOn.Format(time.DateOnly))
+	}
+	d.NetKg = d.GrossKg - d.TareKg
+	return nil
+}
+
+// admissible refuses holders who may not deliver or enter today: inactive,
+// merged, suspended, blacklisted or expired ones. card is the holder's
+// printed card number.
+func admissible(u *model.User, card string, today time.Time) error {
+	switch {
+	case u.MergedInto != "":
+		return fmt.Errorf("kartu %s sudah digabung ke %s", card, u.MergedInto)
+	case !u.Active:
+		return fmt.Errorf("kartu %s nonaktif: %s", card, u.DeleteReason)
+	}
+	switch effectiveState(u, today) {
+	case model.StateSuspended:
+		return fmt.Errorf("kartu %s ditangguhkan: %s", card, u.StateReason)
+	case model.StateBlacklisted:
+		return fmt.Errorf("kartu %s diblokir: %s", card, u.StateReason)
+	}
+	if u.ValidUntil != nil && today.After(dateOf(*u.ValidUntil)) {
+		return fmt.Errorf("kartu %s kedaluwarsa sejak %s", card, u.ValidUntil.Format(util.CardDateLayout))
+	}
+	return nil
+}
+
+// rollUpMonths merges daily totals, oldest first, into monthly ones keyed YYYY-MM.
+func rollUpMonths(days []model.DeliveryTotal) []model.DeliveryTotal {
+	months := []model.DeliveryTotal{}
+	for _, d := range days {
+		key := d.Key[:7]
+		if n := len(months); n == 0 || months[n-1].Key != key {
+			months = append(months, model.DeliveryTotal{Key: key})
+		}
+		addTotal(&months[len(months)-1], d)
+	}
+	return months
+}
+
+func addTotal(sum *model.DeliveryTotal, t model.DeliveryTotal) {
+	sum.Count += t.Count
+	sum.GrossKg += t.GrossKg
+	sum.TareKg += t.TareKg
+	sum.NetKg += t.NetKg
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idcard/internal/model"
+	"idcard/internal/util"
+	"sort"
+	"time"
+)
+
+const (
+	// maxNIKDistance is how many digits a NIK may differ by and still be
+	// treated as a typo of the same person.
+	maxNIKDistance = 2
+	// Both the name and the address must be this close for a fuzzy match
+	duplicateNameScore    = 0.6
+	duplicateAddressScore = 0.5
+)
+
+type (
+	// duplicateIndex holds every holder not merged away, so that many
+	// records can be checked with a single query.
+	duplicateIndex struct {
+		users   []model.User
+		byID    map[string]int
+		byPhone map[string][]int
+		names   []string
+		addrs   []string
+	}
+)
+
+func (s *userServ) loadDuplicateIndex(ctx context.Context) (*duplicateIndex, error) {
+	users, err := s.repo.ListUnmerged(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := &duplicateIndex{
+		users:   users,
+		byID:    make(map[string]int, len(users)),
+		byPhone: map[string][]int{},
+		names:   make([]string, len(users)),
+		addrs:   make([]string, len(users)),
+	}
+	for i, u := range users {
+		idx.byID[u.ID] = i
+		idx.byPhone[u.Phone] = append(idx.byPhone[u.Phone], i)
+		idx.names[i], idx.addrs[i] = util.NameKey(u.Name), util.AddressKey(u.Address)
+	}
+	return idx, nil
+}
+
+// match lists the holders that may be the same person as u: a NIK at most
+// two digits off, the same phone, or a similar name at a similar address
+// among the active ones. u itself is never reported.
+func (idx *duplicateIndex) match(u *model.User) []model.DuplicateCandidate {
+	found := map[int]*model.DuplicateCandidate{}
+	add := func(i int, reason string) {
+		if idx.users[i].ID == u.ID {
+			return
+		}
+		if found[i] == nil {
+			found[i] = &model.DuplicateCandidate{User: idx.users[i]}
+		}
+		found[i].Reasons = append(found[i].Reasons, reason)
+	}
+
+	if u.NIK != "" {
+		for i, c := range idx.users {
+			if util.NIKDistance(u.NIK, c.NIK) <= maxNIKDistance {
+				add(i, model.DuplicateNIK)
+			}
+		}
+	}
+	if phone, err := util.NormalizePhone(u.Phone); err == nil {
+		for _, i := range idx.byPhone[phone] {
+			add(i, model.DuplicatePhone)
+		}
+	}
+	if name, addr := util.NameKey(u.Name), util.AddressKey(u.Address); name != "" && addr != "" {
+		for i, c := range idx.users {
+			if c.Active && util.Similarity(name, idx.names[i]) >= duplicateNameScore &&
+				util.TrigramSimilarity(addr, idx.addrs[i]) >= duplicateAddressScore {
+				add(i, model.DuplicateNameAddress)
+			}
+		}
+	}
+
+	res := make([]model.DuplicateCandidate, 0, len(found))
+	for _, c := range found {
+		res = append(res, *c)
+	}
+	// Most matching checks first
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Reasons) != len(res[j].Reasons) {
+			return len(res[i].Reasons) > len(res[j].Reasons)
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// FindDuplicates lists holders that may be the same person as u. u itself
+// and holders merged away are never reported.
+func (s *userServ) FindDuplicates(ctx context.Context, u *model.User) ([]model.DuplicateCandidate, error) {
+	idx, err := s.loadDuplicateIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.presignCandidates(ctx, idx.match(u))
+}
+
+func (s *userServ) presignCandidates(ctx context.Context, candidates []model.DuplicateCandidate) ([]model.DuplicateCandidate, error) {
+	for i := range candidates {
+		if err := s.presignPhoto(ctx, &candidates[i].User); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// MergeUsers keeps keepID and retires retireID as its duplicate: the audit
+// trail, inspections and deliveries of retireID move to keepID, whose
+// rating is recomputed, and retireID is deactivated for good, keeping its
+// row so the ID is never reissued.
+func (s *userServ) MergeUsers(ctx context.Context, keepID, retireID string) error {
+	if keepID == "" || retireID == "" {
+		return errors.New("both holders are required")
+	}
+	if keepID == retireID {
+		return errors.New("cannot merge a holder into itself")
+	}
+
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	keep, err := s.repo.GetUserForUpdate(ctx, tx, keepID)
+	if err != nil {
+		return fmt.Errorf("holder %s: %w", keepID, err)
+	}
+	if keep.MergedInto != "" {
+		return fmt.Errorf("holder %s was merged into %s", keepID, keep.MergedInto)
+	}
+	retire, err := s.repo.GetUserForUpdate(ctx, tx, retireID)
+	if err != nil {
+		return fmt.Errorf("holder %s: %w", retireID, err)
+	}
+	if retire.MergedInto != "" {
+		return fmt.Errorf("holder %s was already merged into %s", retireID, retire.MergedInto)
+	}
+
+	if _, err := s.auditRepo.Reassign(ctx, tx, retireID, keepID); err != nil {
+		return fmt.Errorf("move history: %w", err)
+	}
+	history, err := s.qc.move(ctx, tx, retireID, keepID)
+	if err != nil {
+		return fmt.Errorf("move inspections: %w", err)
+	}
+	if _, err := s.deliveries.Reassign(ctx, tx, retireID, keepID); err != nil {
+		return fmt.Errorf("move deliveries: %w", err)
+	}
+	reason := fmt.Sprintf("merged into %s", keepID)
+	if err := s.repo.Merge(ctx, tx, retireID, keepID, reason, time.Now()); err != nil {
+		return err
+	}
+
+	after := *retire
+	after.Active, after.DeleteReason, after.MergedInto = false, reason, keepID
+	if err := s.audit(ctx, tx, retireID, model.AuditMerge, diffUser(retire, &after)); err != nil {
+		return err
+	}
+	kept := *keep
+	if history != nil {
+		now := time.Now()
+		kept.Rating, kept.RatedAt = history.Rating, &now
+	}
+	merged := diffUser(keep, &kept)
+	merged["merged_from"] = model.FieldChange{After: retireID}
+	if err := s.audit(ctx, tx, keepID, model.AuditMerge, merged); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
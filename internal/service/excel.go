@@ -5,6 +5,7 @@ import (
 	"idcard/internal/model"
 	"io"
 	"log"
+	"time"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -13,6 +14,7 @@ type (
 	ExcelService interface {
 		UpdateExcel(u *model.User) error
 		ParseExcel(file io.Reader) ([][]string, error)
+		WriteDeliveries(report *model.DeliveryReport, out io.Writer) error
 	}
 
 	excelSvc struct{}
@@ -77,3 +79,44 @@ func (s *excelSvc) ParseExcel(file io.Reader) ([][]string, error) {
 	}
 	return rows, nil
 }
+
+// WriteDeliveries exports a delivery log with its totals per holder and per
+// period, one sheet each.
+func (s *excelSvc) WriteDeliveries(report *model.DeliveryReport, out io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	deliveries := [][]any{{"Tanggal", "ID", "Nama", "Kendaraan", "Material", "Bruto (kg)", "Tara (kg)", "Netto (kg)", "QC", "Catatan", "Petugas"}}
+	for _, d := range report.Deliveries {
+		deliveries = append(deliveries, []any{d.DeliveredOn.Format(time.DateOnly), d.UserID, d.Name, d.Vehicle, d.Material, d.GrossKg, d.TareKg, d.NetKg, d.Grade, d.Notes, d.Operator})
+	}
+	byHolder := [][]any{{"ID", "Nama", "Jumlah", "Bruto (kg)", "Tara (kg)", "Netto (kg)"}}
+	for _, t := range report.ByHolder {
+		byHolder = append(byHolder, []any{t.Key, t.Name, t.Count, t.GrossKg, t.TareKg, t.NetKg})
+	}
+	byPeriod := [][]any{{"Periode", "Jumlah", "Bruto (kg)", "Tara (kg)", "Netto (kg)"}}
+	for _, t := range report.ByPeriod {
+		byPeriod = append(byPeriod, []any{t.Key, t.Count, t.GrossKg, t.TareKg, t.NetKg})
+	}
+	byPeriod = append(byPeriod, []any{"Total", report.Total.Count, report.Total.GrossKg, report.Total.TareKg, report.Total.NetKg})
+
+	f.SetSheetName("Sheet1", "setoran")
+	for _, sheet := range []struct {
+		name string
+		rows [][]any
+	}{{"setoran", deliveries}, {"per pemegang", byHolder}, {"per periode", byPeriod}} {
+		if _, err := f.NewSheet(sheet.name); err != nil {
+			return err
+		}
+		for i, row := range sheet.rows {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			if err := f.SetSheetRow(sheet.name, cell, &row); err != nil {
+				return err
+			}
+		}
+	}
+	return f.Write(out)
+}
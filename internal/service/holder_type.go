@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"idcard/internal/util"
+	"os"
+	"strings"
+)
+
+type (
+	HolderTypeService interface {
+		ListHolderTypes(ctx context.Context) ([]model.HolderType, error)
+		GetHolderType(ctx context.Context, code string) (*model.HolderType, error)
+		CreateHolderType(ctx context.Context, t *model.HolderType) error
+		UpdateHolderType(ctx context.Context, t *model.HolderType) error
+		DeleteHolderType(ctx context.Context, code string) error
+	}
+	holderTypeServ struct {
+		repo repository.HolderTypeRepository
+		ids  *config.IDScheme
+	}
+)
+
+var ErrUnknownHolderType = errors.New("unknown holder type")
+
+func NewHolderTypeService(repo repository.HolderTypeRepository, ids *config.IDScheme) HolderTypeService {
+	return &holderTypeServ{repo: repo, ids: ids}
+}
+
+func (s *holderTypeServ) ListHolderTypes(ctx context.Context) ([]model.HolderType, error) {
+	return s.repo.List(ctx)
+}
+
+// GetHolderType returns the type of code, or ErrUnknownHolderType.
+func (s *holderTypeServ) GetHolderType(ctx context.Context, code string) (*model.HolderType, error) {
+	t, err := s.repo.Get(ctx, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownHolderType, code)
+	}
+	return t, err
+}
+
+func (s *holderTypeServ) CreateHolderType(ctx context.Context, t *model.HolderType) error {
+	if err := validateHolderType(t, s.ids); err != nil {
+		return err
+	}
+	if _, err := s.repo.Get(ctx, t.Code); err == nil {
+		return fmt.Errorf("holder type %s already exists", t.Code)
+	}
+	if err := s.checkUnique(ctx, t); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, t)
+}
+
+// UpdateHolderType edits a type. Changing IDPrefix only affects IDs issued
+// afterwards; they are numbered from a fresh sequence.
+func (s *holderTypeServ) UpdateHolderType(ctx context.Context, t *model.HolderType) error {
+	if err := validateHolderType(t, s.ids); err != nil {
+		return err
+	}
+	if err := s.checkUnique(ctx, t); err != nil {
+		return err
+	}
+	err := s.repo.Update(ctx, t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %q", ErrUnknownHolderType, t.Code)
+	}
+	return err
+}
+
+// DeleteHolderType removes a type that no holder uses, active or not.
+func (s *holderTypeServ) DeleteHolderType(ctx context.Context, code string) error {
+	n, err := s.repo.CountUsers(ctx, code)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("holder type %s is used by %d holder(s)", code, n)
+	}
+	err = s.repo.Delete(ctx, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %q", ErrUnknownHolderType, code)
+	}
+	return err
+}
+
+// checkUnique rejects a code or ID prefix already used by another type, as
+// two types sharing a prefix would draw IDs from one sequence.
+func (s *holderTypeServ) checkUnique(ctx context.Context, t *model.HolderType) error {
+	types, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range types {
+		if other.Code == t.Code {
+			continue
+		}
+		if other.IDPrefix == t.IDPrefix {
+			return fmt.Errorf("ID prefix %s is already used by %s", t.IDPrefix, other.Label)
+		}
+	}
+	return nil
+}
+
+// validateHolderType normalizes t in place and rejects unusable values.
+// IDPrefix defaults to Code and the card templates to the standard card.
+func validateHolderType(t *model.HolderType, ids *config.IDScheme) error {
+	t.Code = strings.ToUpper(strings.TrimSpace(t.Code))
+	t.IDPrefix = strings.ToUpper(strings.TrimSpace(t.IDPrefix))
+	t.Label = strings.TrimSpace(t.Label)
+	t.PDFForm = strings.TrimSpace(t.PDFForm)
+	t.CardTemplate = strings.TrimSpace(t.CardTemplate)
+	t.BackTemplate = strings.TrimSpace(t.BackTemplate)
+	if t.IDPrefix == "" {
+		t.IDPrefix = t.Code
+	}
+	if t.CardTemplate == "" {
+		t.CardTemplate = templatePath
+	}
+	if t.BackTemplate == "" {
+		t.BackTemplate = backTemplatePath
+	}
+
+	if !isUpperWord(t.Code) {
+		return fmt.Errorf("code must be 1 to 8 letters A-Z, got %q", t.Code)
+	}
+	if !isUpperWord(t.IDPrefix) {
+		return fmt.Errorf("ID prefix must be 1 to 8 letters A-Z, got %q", t.IDPrefix)
+	}
+	if t.Label == "" {
+		return errors.New("label is required")
+	}
+	if t.PDFForm == "" {
+		return errors.New("PDF form role is required")
+	}
+	if st, err := os.Stat(t.CardTemplate); err != nil || st.IsDir() {
+		return fmt.Errorf("card template %s not found", t.CardTemplate)
+	}
+	if st, err := os.Stat(t.BackTemplate); err != nil || st.IsDir() {
+		return fmt.Errorf("card back template %s not found", t.BackTemplate)
+	}
+	// The longest card number of the type must still get its barcode
+	longest := &util.CardData{Text: map[string]string{"card_no": ids.LongestCardNo(t.IDPrefix)}}
+	for _, path := range []string{t.CardTemplate, t.BackTemplate} {
+		layout, err := util.LoadCardLayout(path)
+		if err != nil {
+			return fmt.Errorf("card layout: %w", err)
+		}
+		if err := layout.CheckBarcodes(longest); err != nil {
+			return fmt.Errorf("card layout %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func isUpperWord(s string) bool {
+	if len(s) < 1 || len(s) > 8 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
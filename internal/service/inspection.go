@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"idcard/internal/config"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	InspectionService interface {
+		AddInspection(ctx context.Context, in *model.Inspection) (*model.InspectionHistory, error)
+		GetInspections(ctx context.Context, userID string) (*model.InspectionHistory, error)
+	}
+	inspectionServ struct {
+		repo    repository.InspectionRepository
+		users   repository.UserRepository
+		audit   repository.AuditRepository
+		formula *config.RatingFormula
+	}
+)
+
+func NewInspectionService(repo repository.InspectionRepository, users repository.UserRepository, audit repository.AuditRepository, formula *config.RatingFormula) InspectionService {
+	return &inspectionServ{repo: repo, users: users, audit: audit, formula: formula}
+}
+
+// AddInspection records a QC inspection and recomputes the rating of its
+// holder in the same transaction.
+func (s *inspectionServ) AddInspection(ctx context.Context, in *model.Inspection) (*model.InspectionHistory, error) {
+	if err := s.validate(in); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.users.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.users.GetUserForUpdate(ctx, tx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if before.MergedInto != "" {
+		return nil, fmt.Errorf("user %s was merged into %s", in.UserID, before.MergedInto)
+	}
+	history, err := s.record(ctx, tx, before, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// record inserts a validated inspection of holder before, read for update
+// in tx, and stores the rating it leads to.
+func (s *inspectionServ) record(ctx context.Context, tx *sql.Tx, before *model.User, in *model.Inspection) (*model.InspectionHistory, error) {
+	if err := s.repo.Create(ctx, tx, in); err != nil {
+		return nil, err
+	}
+	list, err := s.repo.ListForUpdate(ctx, tx, in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	history := s.history(list)
+
+	now := time.Now()
+	if err := s.users.SetRating(ctx, tx, in.UserID, history.Rating, now); err != nil {
+		return nil, err
+	}
+	after := *before
+	after.Rating, after.RatedAt = history.Rating, &now
+	changes := diffUser(before, &after)
+	changes["inspection"] = model.FieldChange{After: fmt.Sprintf("#%d %s grade %s", in.ID, in.InspectedOn.Format(time.DateOnly), in.Grade)}
+	if err := writeAudit(ctx, s.audit, tx, in.UserID, model.AuditInspect, changes); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// move hands the inspections of holder from over to holder to inside tx,
+// e.g. when they are merged, and stores the rating to then has. It returns
+// nil when neither holder was inspected.
+func (s *inspectionServ) move(ctx context.Context, tx *sql.Tx, from, to string) (*model.InspectionHistory, error) {
+	if _, err := s.repo.Reassign(ctx, tx, from, to); err != nil {
+		return nil, err
+	}
+	list, err := s.repo.ListForUpdate(ctx, tx, to)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	history := s.history(list)
+	if err := s.users.SetRating(ctx, tx, to, history.Rating, time.Now()); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetInspections returns the QC record of userID scored with the current formula.
+func (s *inspectionServ) GetInspections(ctx context.Context, userID string) (*model.InspectionHistory, error) {
+	list, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.history(list), nil
+}
+
+func (s *inspectionServ) validate(in *model.Inspection) error {
+	in.Grade = strings.ToUpper(strings.TrimSpace(in.Grade))
+	if _, ok := s.formula.Grades[in.Grade]; !ok {
+		return fmt.Errorf("unknown grade %q", in.Grade)
+	}
+	if strings.TrimSpace(in.Inspector) == "" {
+		return fmt.Errorf("nama inspektur masih kosong")
+	}
+	for _, p := range []struct {
+		name string
+		v    *float64
+	}{{"moisture", in.Moisture}, {"contamination", in.Contamination}} {
+		if p.v != nil && (*p.v < 0 || *p.v > 100) {
+			return fmt.Errorf("%s must be a percentage between 0 and 100", p.name)
+		}
+	}
+	today := dateOf(time.Now())
+	if in.InspectedOn.IsZero() {
+		in.InspectedOn = today
+	}
+	if in.InspectedOn.After(today) {
+		return fmt.Errorf("inspection date %s is in the future", in.InspectedOn.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// history scores list, given newest first, and replays it oldest first to
+// build the rating trend.
+func (s *inspectionServ) history(list []model.Inspection) *model.InspectionHistory {
+	h := &model.InspectionHistory{Inspections: list, Trend: make([]model.RatingPoint, 0, len(list))}
+	for i := range list {
+		list[i].Score = s.score(list[i])
+	}
+
+	chrono := slices.Clone(list)
+	slices.Reverse(chrono)
+	for i := range chrono {
+		h.Trend = append(h.Trend, model.RatingPoint{
+			Date:   chrono[i].InspectedOn,
+			Score:  chrono[i].Score,
+			Rating: s.rolling(chrono[:i+1]),
+		})
+	}
+	if n := len(h.Trend); n > 0 {
+		h.Rating = h.Trend[n-1].Rating
+	}
+	return h
+}
+
+// score grades one inspection, minus the penalty for findings over the limits.
+func (s *inspectionServ) score(in model.Inspection) int {
+	f := s.formula
+	score := float64(f.Grades[in.Grade])
+	if in.Moisture != nil {
+		score -= f.Penalty * math.Max(0, *in.Moisture-f.MoistureLimit)
+	}
+	if in.Contamination != nil {
+		score -= f.Penalty * math.Max(0, *in.Contamination-f.ContaminationLimit)
+	}
+	return int(math.Round(math.Max(0, math.Min(100, score))))
+}
+
+// rolling is the decayed average score of the last Window inspections of
+// chrono, which is ordered oldest first.
+func (s *inspectionServ) rolling(chrono []model.Inspection) int {
+	var sum, weights float64
+	w := 1.0
+	for i := len(chrono) - 1; i >= 0 && len(chrono)-i <= s.formula.Window; i-- {
+		sum += w * float64(chrono[i].Score)
+		weights += w
+		w *= s.formula.Decay
+	}
+	if weights == 0 {
+		return 0
+	}
+	return int(math.Round(sum / weights))
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
 	"idcard/internal/model"
+	"idcard/internal/util"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
@@ -11,52 +15,67 @@ import (
 
 type (
 	PdfService interface {
-		PrintPDF(user *model.User, outputPath string) error
+		PrintPDF(user *model.User, role string, out io.Writer) error
+		PrintCard(front, back []byte, out io.Writer) error
 	}
 
 	pdfSvc struct{}
 )
 
+// siteRules are the rules of conduct on the company premises, both declared
+// in the registration form and printed on the back of the card.
+var siteRules = []string{
+	"Tidak mengambil gambar/foto/video di area perusahaan.",
+	"Tidak merokok di area perusahaan.",
+	"Tidak melanggar batas kecepatan kendaraan di area perusahaan.",
+}
+
 func NewPdfService() PdfService {
 	return &pdfSvc{}
 }
 
-func (s *pdfSvc) PrintPDF(user *model.User, outputPath string) error {
+// PrintPDF writes the registration form of user, who registers as role
+// (e.g. "Penyetor Afval").
+func (s *pdfSvc) PrintPDF(user *model.User, role string, out io.Writer) error {
 	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.SetTitle("Formulir Pendaftaran Penyetor Afval", false)
+	pdf.SetTitle("Formulir Pendaftaran "+role, false)
 	pdf.AddPage()
 
 	pdf.SetFont("Arial", "B", 14)
-	pdf.CellFormat(0, 10, "Formulir Pendaftaran Penyetor Afval", "", 1, "C", false, 0, "")
+	pdf.CellFormat(0, 10, "Formulir Pendaftaran "+role, "", 1, "C", false, 0, "")
 	pdf.Ln(0)
 	pdf.SetFont("Arial", "", 12)
 	pdf.CellFormat(0, 10, "PT. Sinar Indah Kertas", "", 1, "C", false, 0, "")
 	pdf.Ln(6)
 
 	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(0, 10, "Identitas Penyetor Afval")
+	pdf.Cell(0, 10, "Identitas "+role)
 	pdf.Ln(8)
 	pdf.Cell(0, 8, fmt.Sprintf("Nama     : %s", user.Name))
 	pdf.Ln(8)
 	pdf.Cell(0, 8, fmt.Sprintf("NIK         : %s", user.NIK))
 	pdf.Ln(8)
-	pdf.Cell(0, 8, fmt.Sprintf("No. Telp  : %s", user.Phone))
+	pdf.Cell(0, 8, fmt.Sprintf("No. Telp  : %s", util.LocalPhone(user.Phone)))
 	pdf.Ln(8)
 	pdf.MultiCell(0, 8, fmt.Sprintf("Alamat    : %s", user.Address), "", "", false)
+	if user.IssuedAt != nil && user.ValidUntil != nil {
+		pdf.Cell(0, 8, fmt.Sprintf("Berlaku   : %s s/d %s", user.IssuedAt.Format(util.CardDateLayout), user.ValidUntil.Format(util.CardDateLayout)))
+		pdf.Ln(8)
+	}
 	pdf.Ln(8)
 
 	// Declaration statements
 	statements := []string{
 		"Dengan menandatangani formulir ini saya menyatakan:",
-		"    1. Saya mengajukan/mendaftar sebagai penyetor afval PT. Sinar Indah Kertas.",
+		"    1. Saya mengajukan/mendaftar sebagai " + strings.ToLower(role) + " PT. Sinar Indah Kertas.",
 		"    2. Afval yang saya setor adalah hasil kegiatan yang sah dan tidak melanggar hukum.",
 		"    3. Saya berkomitmen untuk menjaga kualitas dan kejujuran dalam setiap setoran.",
 		"    4. Saya bersedia menjalani proses inspeksi & verifikasi sesuai sistem QC yang diterapkan.",
 		"  5. PT. Sinar Indah Kertas berhak menolak apabila kualitas afval tidak memenuhi standar            perusahaan.",
 		"    6. Saya bersedia mengikuti tata tertib yang berlaku, di antaranya:",
-		"       a. Tidak mengambil gambar/foto/video di area perusahaan.",
-		"       b. Tidak merokok di area perusahaan.",
-		"       c. Tidak melanggar batas kecepatan kendaraan di area perusahaan.",
+		"       a. " + siteRules[0],
+		"       b. " + siteRules[1],
+		"       c. " + siteRules[2],
 		"   7. Saya menyadari bahwa pelanggaran terhadap komitmen dapat berdampak pada pemutusan            kerjasama.",
 		"  8. Saya menyatakan bahwa data & pernyataan yang saya berikan adalah benar dan dapat             dipertanggungjawabkan.",
 	}
@@ -80,5 +99,24 @@ func (s *pdfSvc) PrintPDF(user *model.User, outputPath string) error {
 	pdf.CellFormat(0, 6, user.Name, "", 1, "R", false, 0, "")
 
 	// Output
-	return pdf.OutputFileAndClose(outputPath)
+	return pdf.Output(out)
+}
+
+// PrintCard writes the front and back PNG images of a card as a two-page
+// PDF at ID-1 card size, for printing both sides.
+func (s *pdfSvc) PrintCard(front, back []byte, out io.Writer) error {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: 54, Ht: 85.6},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	opt := gofpdf.ImageOptions{ImageType: "PNG"}
+	for i, side := range [][]byte{front, back} {
+		name := fmt.Sprintf("side%d", i)
+		pdf.AddPage()
+		pdf.RegisterImageOptionsReader(name, opt, bytes.NewReader(side))
+		pdf.ImageOptions(name, 0, 0, 54, 85.6, false, opt, 0, "")
+	}
+	return pdf.Output(out)
 }
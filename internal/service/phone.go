@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"idcard/internal/model"
+	"idcard/internal/repository"
+	"idcard/internal/util"
+	"sort"
+)
+
+// NormalizePhones rewrites every stored phone to E.164 in one transaction,
+// auditing each change. Phones that cannot be normalized are left alone and
+// reported. With dryRun nothing is written. It needs only the database, so
+// the maintenance command runs without the storage and card configuration.
+func NormalizePhones(ctx context.Context, users repository.UserRepository, audit repository.AuditRepository, dryRun bool) (*model.PhoneNormalization, error) {
+	phones, err := users.ListPhones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := users.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, 0, len(phones))
+	for id := range phones {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	res := &model.PhoneNormalization{Checked: len(ids), Invalid: map[string]string{}}
+	for _, id := range ids {
+		normalized, err := util.NormalizePhone(phones[id])
+		if err != nil {
+			res.Invalid[id] = err.Error()
+			continue
+		}
+		if normalized == phones[id] {
+			continue
+		}
+		res.Changed++
+		if dryRun {
+			continue
+		}
+		if err := users.UpdatePhone(ctx, tx, id, normalized); err != nil {
+			return nil, fmt.Errorf("update phone of %s: %w", id, err)
+		}
+		changes := map[string]model.FieldChange{"phone": {Before: phones[id], After: normalized}}
+		if err := writeAudit(ctx, audit, tx, id, model.AuditUpdate, changes); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return res, nil
+	}
+	return res, tx.Commit()
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"idcard/internal/model"
+	"idcard/internal/util"
+	"sort"
+	"time"
+)
+
+// ageGroups are the brackets of the demographics report, by lowest age.
+var ageGroups = []struct {
+	label string
+	from  int
+}{
+	{"< 20", 0},
+	{"20-29", 20},
+	{"30-39", 30},
+	{"40-49", 40},
+	{"50-59", 50},
+	{"60+", 60},
+}
+
+// GetDemographics reports active holders by province, regency, gender and
+// age group as decoded from their NIK.
+func (s *userServ) GetDemographics(ctx context.Context) (*model.DemographicsReport, error) {
+	rows, err := s.repo.Demographics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &model.DemographicsReport{Genders: map[string]int{}}
+	provinces, regencies := map[string]int{}, map[string]int{}
+	ages := make([]int, len(ageGroups))
+	for _, r := range rows {
+		report.Total += r.Count
+		if r.ProvinceCode == "" || r.BirthDate == nil {
+			report.Unknown += r.Count
+			continue
+		}
+		provinces[r.ProvinceCode] += r.Count
+		regencies[r.RegencyCode] += r.Count
+		report.Genders[r.Gender] += r.Count
+
+		age := util.Age(*r.BirthDate, now)
+		for i := len(ageGroups) - 1; i >= 0; i-- {
+			if age >= ageGroups[i].from {
+				ages[i] += r.Count
+				break
+			}
+		}
+	}
+
+	report.Provinces = regionCounts(provinces, util.Provinces)
+	report.Regencies = regionCounts(regencies, nil)
+	for i, g := range ageGroups {
+		report.AgeGroups = append(report.AgeGroups, model.AgeGroupCount{Label: g.label, Count: ages[i]})
+	}
+	return report, nil
+}
+
+// regionCounts sorts counts by size, largest first, naming codes found in names.
+func regionCounts(counts map[string]int, names map[string]string) []model.RegionCount {
+	res := make([]model.RegionCount, 0, len(counts))
+	for code, n := range counts {
+		res = append(res, model.RegionCount{Code: code, Name: names[code], Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Code < res[j].Code
+	})
+	return res
+}
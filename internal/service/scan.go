@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idcard/internal/model"
+	"idcard/internal/util"
+	"strings"
+	"time"
+)
+
+// ScanCard checks the QR code read from a card against the holder's current
+// record: a forged code, a card replaced by a later issue, or a holder who
+// may not enter is refused.
+func (s *userServ) ScanCard(ctx context.Context, code string) (*model.CardScan, error) {
+	userID, issue, err := s.codes.Decode(code)
+	if err != nil {
+		return &model.CardScan{Reason: "kode QR tidak valid, kartu palsu atau rusak"}, nil
+	}
+	scan := &model.CardScan{Issue: issue}
+
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		scan.Reason = fmt.Sprintf("kartu %s tidak terdaftar", s.ids.Display(userID))
+		return scan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	today := dateOf(time.Now())
+	s.annotate(u, today)
+	if err := s.presignPhoto(ctx, u); err != nil {
+		return nil, err
+	}
+	scan.User = u
+
+	card := s.ids.Display(u.ID)
+	if issue != u.CardIssue {
+		scan.Reason = fmt.Sprintf("kartu %s cetakan ke-%d sudah diganti cetakan ke-%d", card, issue, u.CardIssue)
+		return scan, nil
+	}
+	scan.Valid = true
+	if err := admissible(u, card, today); err != nil {
+		scan.Reason = err.Error()
+		return scan, nil
+	}
+	scan.Admitted = true
+	return scan, nil
+}
+
+// LookupCard finds the holder of a card number read by a barcode scanner
+// and tells whether they may deliver today.
+func (s *userServ) LookupCard(ctx context.Context, cardNo string) (*model.CardLookup, error) {
+	u, err := s.repo.GetUserByID(ctx, s.ids.ParseCardNo(cardNo))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.presignPhoto(ctx, u); err != nil {
+		return nil, err
+	}
+	t, err := s.types.GetHolderType(ctx, u.Status)
+	if err != nil {
+		return nil, err
+	}
+	card := s.ids.Display(u.ID)
+	lookup := &model.CardLookup{CardNo: card, Name: util.NormalizeName(u.Name), HolderType: t.Label, Photo: u.Photo, Admitted: true}
+	if err := admissible(u, card, dateOf(time.Now())); err != nil {
+		lookup.Admitted, lookup.Reason = false, err.Error()
+	}
+	return lookup, nil
+}
+
+// VerifyCard checks a card for the public verification page, from the QR
+// code payload or a typed card number, and reveals only what a guard needs
+// to match the card to its bearer.
+func (s *userServ) VerifyCard(ctx context.Context, ref string) (*model.CardCheck, error) {
+	check := &model.CardCheck{Status: model.CardInvalid}
+	userID, issue, err := s.codes.Decode(ref)
+	switch {
+	case err == nil:
+		check.Signed = true
+	case strings.Contains(ref, "."):
+		// Card numbers have no dots, so this was a code that failed to verify
+		return check, nil
+	default:
+		userID = s.ids.ParseCardNo(ref)
+	}
+
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		check.Status = model.CardUnknown
+		return check, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	today := dateOf(time.Now())
+	s.annotate(u, today)
+	if err := s.presignPhoto(ctx, u); err != nil {
+		return nil, err
+	}
+	t, err := s.types.GetHolderType(ctx, u.Status)
+	if err != nil {
+		return nil, err
+	}
+	check.CardNo, check.MaskedName, check.HolderType = s.ids.Display(u.ID), util.MaskName(u.Name), t.Label
+	check.Photo, check.ValidUntil = u.Photo, u.ValidUntil
+
+	switch {
+	case !u.Active || u.MergedInto != "" || u.State == model.StateBlacklisted:
+		check.Status = model.CardRevoked
+	case check.Signed && issue != u.CardIssue:
+		check.Status = model.CardReplaced
+	case u.State == model.StateSuspended:
+		check.Status = model.CardSuspended
+	case u.Expired:
+		check.Status = model.CardExpired
+	default:
+		check.Status = model.CardValid
+	}
+	return check, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"idcard/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid state transition")
+	ErrBlacklisted       = errors.New("NIK is blacklisted")
+)
+
+// stateTransitions lists the states each state may move to. Lifting a
+// blacklisting goes back to active, never straight to suspended.
+var stateTransitions = map[string][]string{
+	model.StateActive:      {model.StateSuspended, model.StateBlacklisted},
+	model.StateSuspended:   {model.StateActive, model.StateBlacklisted},
+	model.StateBlacklisted: {model.StateActive},
+}
+
+// ChangeUserState moves a holder to c.State. Blacklisting also bars its NIK
+// from registering again until the holder is reinstated.
+func (s *userServ) ChangeUserState(ctx context.Context, userID string, c model.StateChange) (*model.User, error) {
+	if strings.TrimSpace(c.Reason) == "" {
+		return nil, errors.New("alasan perubahan status masih kosong")
+	}
+	today := dateOf(time.Now())
+	from := today
+	if c.EffectiveFrom != nil {
+		from = dateOf(*c.EffectiveFrom)
+	}
+	if from.After(today) {
+		return nil, fmt.Errorf("effective date %s is in the future", from.Format(time.DateOnly))
+	}
+	if c.EffectiveUntil != nil {
+		if c.State != model.StateSuspended {
+			return nil, fmt.Errorf("only suspensions take an end date")
+		}
+		if c.EffectiveUntil.Before(from) {
+			return nil, fmt.Errorf("suspension ends before it starts")
+		}
+	}
+
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.repo.GetUserForUpdate(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if before.MergedInto != "" {
+		return nil, fmt.Errorf("user %s was merged into %s", userID, before.MergedInto)
+	}
+	current := effectiveState(before, today)
+	if !slices.Contains(stateTransitions[current], c.State) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, c.State)
+	}
+
+	if err := s.repo.SetState(ctx, tx, userID, c.State, c.Reason, from, c.EffectiveUntil); err != nil {
+		return nil, err
+	}
+	switch {
+	case c.State == model.StateBlacklisted:
+		err = s.blacklist.Add(ctx, tx, &model.BlacklistedNIK{NIK: before.NIK, UserID: userID, Reason: c.Reason})
+	case current == model.StateBlacklisted:
+		err = s.blacklist.Remove(ctx, tx, before.NIK)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after := *before
+	after.State, after.StateReason, after.StateFrom, after.StateUntil = c.State, c.Reason, &from, c.EffectiveUntil
+	if err := s.audit(ctx, tx, userID, model.AuditState, diffUser(before, &after)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.annotate(&after, today)
+	return &after, s.presignPhoto(ctx, &after)
+}
+
+// checkBlacklist refuses nik when it was barred under another holder than
+// userID.
+func (s *userServ) checkBlacklist(ctx context.Context, nik, userID string) error {
+	b, err := s.blacklist.Get(ctx, nik)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if b.UserID == userID {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (%s, %s)", ErrBlacklisted, nik, b.UserID, b.Reason)
+}
+
+// effectiveState is the state of u on today; a suspension lapses after its
+// end date.
+func effectiveState(u *model.User, today time.Time) string {
+	if u.State == "" {
+		return model.StateActive
+	}
+	if u.State == model.StateSuspended && u.StateUntil != nil && today.After(dateOf(*u.StateUntil)) {
+		return model.StateActive
+	}
+	return u.State
+}
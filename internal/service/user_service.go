@@ -3,34 +3,68 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"idcard/internal/config"
 	"idcard/internal/model"
 	"idcard/internal/repository"
 	"idcard/internal/util"
+	"image"
 	"io"
-	"strconv"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type (
 	UserService interface {
-		CreateUserAction(ctx context.Context, u *model.User, photo []byte) error
-		GenerateUserID(ctx context.Context, status string) (string, error)
-		GetUserList(ctx context.Context, limit uint8) (*[]model.User, error)
+		CreateUserAction(ctx context.Context, u *model.User, photo []byte, reservation string) error
+		ReserveUserID(ctx context.Context, status string) (*model.IDReservation, error)
+		GetUserList(ctx context.Context, f model.UserFilter) (*model.UserPage, error)
+		SearchUsersByName(ctx context.Context, f model.UserFilter) (*model.NameMatchPage, error)
+		GetDemographics(ctx context.Context) (*model.DemographicsReport, error)
+		FindDuplicates(ctx context.Context, u *model.User) ([]model.DuplicateCandidate, error)
+		MergeUsers(ctx context.Context, keepID, retireID string) error
+		RenewUser(ctx context.Context, userID string) (*model.User, error)
+		ScanCard(ctx context.Context, code string) (*model.CardScan, error)
+		VerifyCard(ctx context.Context, ref string) (*model.CardCheck, error)
+		LookupCard(ctx context.Context, cardNo string) (*model.CardLookup, error)
+		ChangeUserState(ctx context.Context, userID string, c model.StateChange) (*model.User, error)
 		GetUserByNik(ctx context.Context, nik string) (*model.User, error)
+		GetUserByID(ctx context.Context, userID string) (*model.User, error)
 		UpdateUserAction(ctx context.Context, user *model.User, photo []byte) error
-		BulkUpsertUser(ctx context.Context, file io.Reader) (int, error)
+		BulkUpsertUser(ctx context.Context, file io.Reader) (*model.ImportReport, error)
+		GetDocument(ctx context.Context, userID, docType string) ([]byte, string, error)
+		GetDocumentURLs(ctx context.Context, userID string) (map[string]string, error)
+		DeactivateUser(ctx context.Context, userID, reason string) error
+		RestoreUser(ctx context.Context, userID string) error
+		DeleteUser(ctx context.Context, userID string) error
+		GetUserHistory(ctx context.Context, userID string) ([]model.AuditEntry, error)
 	}
 	userServ struct {
 		repo          repository.UserRepository
+		auditRepo     repository.AuditRepository
+		blacklist     repository.BlacklistRepository
+		types         HolderTypeService
 		storageClient config.Client
+		ids           *config.IDScheme
+		validity      *config.CardValidity
+		codes         *config.CardCode
+		back          *config.CardBack
+		qc            *inspectionServ
+		deliveries    repository.DeliveryRepository
 		pdfSvc        PdfService
 		excelSvc      ExcelService
 	}
 
 	Result struct {
+		ID       string
 		NIK      string
 		Affected bool
 		Err      error
@@ -38,105 +72,451 @@ type (
 )
 
 const (
-	templatePath = util.PathToAssets + "kartu.png"
+	// templatePath and backTemplatePath are the default card backgrounds
+	// of new holder types
+	templatePath     = util.PathToAssets + "kartu.png"
+	backTemplatePath = util.PathToAssets + "BACK.png"
+	avatarPath       = util.PathToAssets + "avatar.png"
+
+	DocCard      = "card"
+	DocCardBack  = "card_back"
+	DocCardPrint = "card_print"
+	DocForm      = "form"
 )
 
-func NewUserService(repo repository.UserRepository, pdf PdfService, excel ExcelService, storage config.Client) UserService {
-	return &userServ{repo: repo, pdfSvc: pdf, excelSvc: excel, storageClient: storage}
+const historyLimit = 200
+
+func NewUserService(repo repository.UserRepository, audit repository.AuditRepository, blacklist repository.BlacklistRepository, types HolderTypeService, pdf PdfService, excel ExcelService, storage config.Client, ids *config.IDScheme, validity *config.CardValidity, codes *config.CardCode, back *config.CardBack,
+	inspections repository.InspectionRepository, formula *config.RatingFormula, deliveries repository.DeliveryRepository) UserService {
+	qc := &inspectionServ{repo: inspections, users: repo, audit: audit, formula: formula}
+	return &userServ{repo: repo, auditRepo: audit, blacklist: blacklist, types: types, pdfSvc: pdf, excelSvc: excel, storageClient: storage, ids: ids, validity: validity, codes: codes, back: back, qc: qc, deliveries: deliveries}
 }
 
-func (s *userServ) CreateUserAction(ctx context.Context, u *model.User, photo []byte) error {
-	if err := s.repo.Create(ctx, u); err != nil {
+// CreateUserAction inserts u under the ID held by the reservation token, or a
+// freshly allocated one when the reservation is missing or has expired.
+// u.ID and u.Photo are updated to the ID actually used.
+func (s *userServ) CreateUserAction(ctx context.Context, u *model.User, photo []byte, reservation string) error {
+	if err := prepareUser(u); err != nil {
+		return err
+	}
+	if err := s.checkBlacklist(ctx, u.NIK, ""); err != nil {
+		return err
+	}
+	t, err := s.types.GetHolderType(ctx, u.Status)
+	if err != nil {
 		return err
 	}
 
-	err := s.imageSequenceAction(ctx, u, photo)
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.assignUserID(ctx, tx, u, s.ids.Scope(t.IDPrefix, time.Now()), reservation); err != nil {
+		return err
+	}
+	u.Photo = util.PathToUploads + u.ID + util.GetFileFormat(u.Photo)
+	u.Active, u.State = true, model.StateActive
+	s.issueCard(u, time.Now())
+
+	if err := s.repo.Create(ctx, tx, u); err != nil {
+		return err
+	}
+	if err := s.audit(ctx, tx, u.ID, model.AuditCreate, diffUser(nil, u)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	err = s.imageSequenceAction(ctx, u, photo)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *userServ) GenerateUserID(ctx context.Context, status string) (string, error) {
-	res, err := s.repo.GetLastUserId(ctx, status)
-	if err != nil && err != sql.ErrNoRows {
-		return "", err
-	} else if err == sql.ErrNoRows {
-		return fmt.Sprintf("%s%03d", status, 1), nil
+// ReserveUserID holds the next ID of holder type status for the registration
+// form. The ID is released for reuse if it is not submitted within
+// util.ReservationTTL.
+func (s *userServ) ReserveUserID(ctx context.Context, status string) (*model.IDReservation, error) {
+	t, err := s.types.GetHolderType(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
 	}
 
-	userCount, err := strconv.Atoi(res[1:])
-	return fmt.Sprintf("%s%03d", status, userCount+1), err
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	scope := s.ids.Scope(t.IDPrefix, time.Now())
+	id, err := s.allocateUserID(ctx, tx, scope)
+	if err != nil {
+		return nil, err
+	}
+	res := &model.IDReservation{ID: id, Token: token, ExpiresAt: time.Now().Add(util.ReservationTTL)}
+	if err := s.repo.CreateReservation(ctx, tx, res.ID, scope, res.Token, res.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return res, tx.Commit()
 }
 
-func (s *userServ) GetUserList(ctx context.Context, limit uint8) (*[]model.User, error) {
-	users, err := s.repo.GetList(ctx, limit)
+// assignUserID keeps u.ID when reservation still holds it, otherwise it
+// allocates a new ID of scope inside tx.
+func (s *userServ) assignUserID(ctx context.Context, tx *sql.Tx, u *model.User, scope, reservation string) error {
+	if u.ID != "" && reservation != "" {
+		ok, err := s.repo.TakeReservation(ctx, tx, u.ID, scope, reservation, time.Now())
+		if err != nil {
+			return fmt.Errorf("take reservation: %w", err)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	id, err := s.allocateUserID(ctx, tx, scope)
+	if err != nil {
+		return err
+	}
+	u.ID = id
+	return nil
+}
+
+// allocateUserID reuses a lapsed reservation of scope if there is one, and
+// draws the next number from the scope sequence otherwise.
+func (s *userServ) allocateUserID(ctx context.Context, tx *sql.Tx, scope string) (string, error) {
+	id, err := s.repo.ReclaimExpiredReservation(ctx, tx, scope, time.Now())
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("reclaim reservation: %w", err)
+	}
+
+	n, err := s.repo.NextUserSeq(ctx, tx, scope)
+	if err != nil {
+		return "", fmt.Errorf("next user sequence: %w", err)
+	}
+	return s.ids.Format(scope, n), nil
+}
+
+func (s *userServ) GetUserList(ctx context.Context, f model.UserFilter) (*model.UserPage, error) {
+	today := dateOf(time.Now())
+	if err := s.validityBounds(&f, today); err != nil {
+		return nil, err
+	}
+
+	page, err := s.repo.GetList(ctx, f)
 	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < len(*users); i++ {
-		(*users)[i].Name = util.NormalizeName((*users)[i].Name)
+	for i := range page.Users {
+		page.Users[i].Name = util.NormalizeName(page.Users[i].Name)
+		s.annotate(&page.Users[i], today)
+		if err := s.presignPhoto(ctx, &page.Users[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	return users, nil
+	return page, nil
+}
+
+// validityBounds turns f.Validity into the ValidUntil bounds of f.
+func (s *userServ) validityBounds(f *model.UserFilter, today time.Time) error {
+	warnTo := today.AddDate(0, 0, s.validity.WarningDays+1)
+	switch f.Validity {
+	case "":
+	case model.ValidityExpired:
+		f.ValidUntilTo = &today
+	case model.ValidityExpiring:
+		f.ValidUntilFrom, f.ValidUntilTo = &today, &warnTo
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidValidity, f.Validity)
+	}
+	return nil
+}
+
+// SearchUsersByName finds holders matching the other constraints of f by a
+// possibly misspelled name in f.Query, best match first.
+func (s *userServ) SearchUsersByName(ctx context.Context, f model.UserFilter) (*model.NameMatchPage, error) {
+	today := dateOf(time.Now())
+	if err := s.validityBounds(&f, today); err != nil {
+		return nil, err
+	}
+	page, err := s.repo.SearchByName(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := page.Matches
+	for i := range matches {
+		matches[i].Name = util.NormalizeName(matches[i].Name)
+		s.annotate(&matches[i].User, today)
+		if err := s.presignPhoto(ctx, &matches[i].User); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
 }
 
 func (s *userServ) GetUserByNik(ctx context.Context, nik string) (*model.User, error) {
 	var u *model.User
 	u, err := s.repo.GetUserByNik(ctx, nik)
+	if err != nil {
+		return nil, err
+	}
+	s.annotate(u, dateOf(time.Now()))
+
+	return u, s.presignPhoto(ctx, u)
+}
+
+func (s *userServ) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	var u *model.User
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.annotate(u, dateOf(time.Now()))
 
-	return u, err
+	return u, s.presignPhoto(ctx, u)
 }
 
 func (s *userServ) UpdateUserAction(ctx context.Context, u *model.User, photo []byte) error {
-	if err := s.repo.UpdateUser(ctx, u); err != nil {
+	if err := prepareUser(u); err != nil {
+		return err
+	}
+	if _, err := s.types.GetHolderType(ctx, u.Status); err != nil {
 		return err
 	}
+	if err := s.checkBlacklist(ctx, u.NIK, u.ID); err != nil {
+		return err
+	}
+
+	// Photo keys never change, so compare content to audit a new picture
+	oldPhoto, err := s.storageClient.Download(ctx, photoKey(u))
+	if err != nil && !errors.Is(err, config.ErrObjectNotFound) {
+		return fmt.Errorf("download photo: %w", err)
+	}
+
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
 
-	err := s.imageSequenceAction(ctx, u, photo)
+	before, err := s.repo.GetUserForUpdate(ctx, tx, u.ID)
+	if err != nil {
+		return err
+	}
+	if before.RatedAt != nil {
+		// QC inspections own the rating once they exist
+		u.Rating = before.Rating
+	}
+	if err := s.repo.UpdateUser(ctx, tx, u); err != nil {
+		return err
+	}
+
+	after := applyEditable(before, u)
+	changes := diffUser(before, after)
+	if oldSum, newSum := checksum(oldPhoto), checksum(photo); oldSum != newSum {
+		changes["photo"] = model.FieldChange{Before: oldSum, After: newSum}
+	}
+	if len(changes) > 0 {
+		if err := s.audit(ctx, tx, u.ID, model.AuditUpdate, changes); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Render from the stored row: the form carries neither the card issue
+	// nor the validity printed on the card
+	err = s.imageSequenceAction(ctx, after, photo)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *userServ) BulkUpsertUser(ctx context.Context, file io.Reader) (int, error) {
+// DeactivateUser soft deletes a holder; it disappears from the list but
+// keeps its ID, documents and photo until restored or deleted for good.
+func (s *userServ) DeactivateUser(ctx context.Context, userID, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("alasan nonaktif masih kosong")
+	}
+	return s.setActive(ctx, userID, false, reason)
+}
+
+func (s *userServ) RestoreUser(ctx context.Context, userID string) error {
+	return s.setActive(ctx, userID, true, "")
+}
+
+func (s *userServ) setActive(ctx context.Context, userID string, active bool, reason string) error {
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.repo.GetUserForUpdate(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if before.MergedInto != "" {
+		return fmt.Errorf("user %s was merged into %s", userID, before.MergedInto)
+	}
+
+	action := model.AuditRestore
+	if active {
+		err = s.repo.Restore(ctx, tx, userID)
+	} else {
+		action = model.AuditDeactivate
+		err = s.repo.Deactivate(ctx, tx, userID, reason, time.Now())
+	}
+	if errors.Is(err, sql.ErrNoRows) && active {
+		return fmt.Errorf("user %s is already active", userID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s is already inactive", userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	after := *before
+	after.Active, after.DeleteReason = active, reason
+	if err := s.audit(ctx, tx, userID, action, diffUser(before, &after)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes a deactivated holder with its photo, card and form.
+// Active holders must be deactivated first, and holders with recorded
+// deliveries or inspections are refused, as those rows would be left
+// without an owner. The stored objects are removed once the row is gone,
+// so a failed delete never leaves a holder without a photo; an object
+// that could not be removed is reported. The audit trail is kept.
+func (s *userServ) DeleteUser(ctx context.Context, userID string) error {
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	u, err := s.repo.GetUserForUpdate(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if u.Active {
+		return fmt.Errorf("user %s is active, deactivate it before deleting", u.ID)
+	}
+	deliveries, err := s.deliveries.CountByUser(ctx, tx, u.ID)
+	if err != nil {
+		return err
+	}
+	inspections, err := s.qc.repo.CountByUser(ctx, tx, u.ID)
+	if err != nil {
+		return err
+	}
+	if deliveries > 0 || inspections > 0 {
+		return fmt.Errorf("user %s has %d delivery(s) and %d inspection(s) and cannot be deleted", u.ID, deliveries, inspections)
+	}
+	if err := s.repo.Delete(ctx, tx, u.ID); err != nil {
+		return err
+	}
+	if err := s.audit(ctx, tx, u.ID, model.AuditDelete, diffUser(u, nil)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range []string{photoKey(u), cardKey(u.ID), cardBackKey(u.ID), formKey(u.ID)} {
+		if err := s.storageClient.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *userServ) GetUserHistory(ctx context.Context, userID string) ([]model.AuditEntry, error) {
+	return s.auditRepo.ListByUser(ctx, userID, historyLimit)
+}
+
+// BulkUpsertUser imports an XLSX sheet in one transaction. The report counts
+// inserted or changed rows and lists possible duplicates among them.
+func (s *userServ) BulkUpsertUser(ctx context.Context, file io.Reader) (*model.ImportReport, error) {
 
 	jobs := make(chan model.User)
 	results := make(chan Result)
-	var affected int
+	var changed []Result
 	var wg sync.WaitGroup
 
 	numWorkers := 4
 
 	rows, err := s.excelSvc.ParseExcel(file)
 	if err != nil {
-		return 0, fmt.Errorf("parse error: %w", err)
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	typeList, err := s.types.ListHolderTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types := map[string]model.HolderType{}
+	for _, t := range typeList {
+		types[t.Code] = t
+	}
+	blacklisted, err := s.blacklist.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	barred := map[string]model.BlacklistedNIK{}
+	for _, b := range blacklisted {
+		barred[b.NIK] = b
 	}
 
 	tx, err := s.repo.Begin(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin tx: %w", err)
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Stop the feeder and workers as soon as one row fails or ctx is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	for range numWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			s.userWorker(ctx, tx, jobs, results)
+			s.userWorker(ctx, tx, types, jobs, results)
 		}()
 	}
 
 	go func() {
+		defer close(jobs)
 		for i := 1; i < len(rows); i++ {
 			ket := ""
-			foto := "static/assets/avatar.png"
+			foto := avatarPath
 			row := rows[i]
 			if len(row) < 7 {
-				results <- Result{Err: fmt.Errorf("row %d incomplete", i+1)}
-				continue
+				select {
+				case results <- Result{Err: fmt.Errorf("row %d incomplete", i+1)}:
+					continue
+				case <-ctx.Done():
+					return
+				}
 			}
 			if len(row) == 8 {
 				ket = row[7]
@@ -155,9 +535,29 @@ func (s *userServ) BulkUpsertUser(ctx context.Context, file io.Reader) (int, err
 				Notes:   ket,
 				Photo:   foto,
 			}
-			jobs <- u
+			err := prepareUser(&u)
+			if _, ok := types[u.Status]; err == nil && !ok {
+				err = fmt.Errorf("%w: %q", ErrUnknownHolderType, u.Status)
+			}
+			if b, ok := barred[u.NIK]; err == nil && ok && b.UserID != u.ID {
+				err = fmt.Errorf("%w: %s (%s, %s)", ErrBlacklisted, u.NIK, b.UserID, b.Reason)
+			}
+			if err != nil {
+				select {
+				case results <- Result{NIK: u.NIK, Err: fmt.Errorf("row %d: %w", i+1, err)}:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			// Only new rows take this validity; upsert keeps that of existing ones
+			s.issueCard(&u, time.Now())
+			select {
+			case jobs <- u:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(jobs)
 	}()
 
 	go func() {
@@ -167,55 +567,186 @@ func (s *userServ) BulkUpsertUser(ctx context.Context, file io.Reader) (int, err
 
 	for res := range results {
 		if res.Err != nil {
-			return 0, fmt.Errorf("bulk update failed: %w", res.Err)
+			return nil, fmt.Errorf("bulk update failed: %w", res.Err)
 		}
 		if res.Affected {
-			affected++
+			changed = append(changed, res)
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("bulk update aborted: %w", err)
+	}
 
 	if err := tx.Commit(); err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return affected, nil
+	// The import is committed; a failed duplicate check must not report
+	// it as failed, so it only warns
+	report := &model.ImportReport{Affected: len(changed), Duplicates: []model.ImportDuplicate{}}
+	if err := s.importDuplicates(ctx, changed, report); err != nil {
+		report.Duplicates = []model.ImportDuplicate{}
+		report.Warning = "pengecekan data ganda gagal: " + err.Error()
+		return report, nil
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool { return report.Duplicates[i].ID < report.Duplicates[j].ID })
+	return report, nil
 }
 
-func (s *userServ) userWorker(ctx context.Context, tx *sql.Tx, jobs <-chan model.User, results chan<- Result) {
-	for u := range jobs {
-		res := Result{NIK: u.NIK}
-		r, err := s.repo.UpsertUser(ctx, tx, u)
+// importDuplicates adds the possible duplicates of each changed row to
+// report, matched against the holder table loaded once.
+func (s *userServ) importDuplicates(ctx context.Context, changed []Result, report *model.ImportReport) error {
+	idx, err := s.loadDuplicateIndex(ctx)
+	if err != nil {
+		return err
+	}
+	for _, res := range changed {
+		i, ok := idx.byID[res.ID]
+		if !ok {
+			continue
+		}
+		u := idx.users[i]
+		candidates, err := s.presignCandidates(ctx, idx.match(&u))
 		if err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			report.Duplicates = append(report.Duplicates, model.ImportDuplicate{ID: u.ID, NIK: u.NIK, Candidates: candidates})
+		}
+	}
+	return nil
+}
+
+func (s *userServ) userWorker(ctx context.Context, tx *sql.Tx, types map[string]model.HolderType, jobs <-chan model.User, results chan<- Result) {
+	for u := range jobs {
+		res := Result{ID: u.ID, NIK: u.NIK}
+		if err := s.upsertAudited(ctx, tx, &u, &res); err != nil {
 			res.Err = fmt.Errorf("upsert NIK %s: %w", u.NIK, err)
-			results <- res
-			continue
 		}
-		if r > 1 {
-			res.Affected = true
+		// Keep the sequence ahead of imported IDs so new registrations don't collide
+		scope := s.ids.Scope(types[u.Status].IDPrefix, time.Now())
+		if n, ok := s.ids.Number(scope, u.ID); res.Err == nil && ok {
+			if err := s.repo.BumpUserSeq(ctx, tx, scope, n); err != nil {
+				res.Err = fmt.Errorf("bump sequence %s: %w", u.ID, err)
+			}
+		}
+		select {
+		case results <- res:
+		case <-ctx.Done():
+			return
 		}
-		results <- res
 	}
 }
 
-func (s *userServ) imageSequenceAction(ctx context.Context, u *model.User, imgByte []byte) error {
-	ext := util.GetFileFormat(u.Photo)
-	mime := util.GetMimeType(u.Photo)
-	if err := util.GenerateIDCard(templatePath, util.NormalizeName(u.Name), u.ID, u.Address, fmt.Sprintf("%s%s.png", util.PathToCard, u.ID), bytes.NewReader(imgByte)); err != nil {
-		err = fmt.Errorf("generate ID card: %w", err)
+// upsertAudited writes one imported row and its audit entry. Unchanged rows
+// are not counted as affected and leave no entry.
+func (s *userServ) upsertAudited(ctx context.Context, tx *sql.Tx, u *model.User, res *Result) error {
+	before, err := s.repo.GetUserForUpdate(ctx, tx, u.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if before != nil && before.RatedAt != nil {
+		u.Rating = before.Rating
+	}
+	if _, err := s.repo.UpsertUser(ctx, tx, *u); err != nil {
 		return err
 	}
 
-	if err := s.pdfSvc.PrintPDF(u, fmt.Sprintf("%s%s.pdf", util.PathToContract, u.ID)); err != nil {
-		err = fmt.Errorf("generate PDF: %w", err)
+	after := u
+	if before != nil {
+		// Upsert keeps the status and soft delete state of existing rows
+		after = applyEditable(before, u)
+		after.Status = before.Status
+	} else {
+		after.Active, after.State = true, model.StateActive
+	}
+	changes := diffUser(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	res.Affected = true
+	return s.audit(ctx, tx, u.ID, model.AuditUpsert, changes)
+}
+
+// prepareUser validates u before it is written: it normalizes the phone to
+// E.164 and decodes the NIK.
+func prepareUser(u *model.User) error {
+	phone, err := util.NormalizePhone(u.Phone)
+	if err != nil {
 		return err
 	}
+	u.Phone = phone
+	return decodeNIK(u)
+}
+
+// decodeNIK validates u.NIK and fills the fields derived from it.
+func decodeNIK(u *model.User) error {
+	n, err := util.ParseNIK(u.NIK, time.Now())
+	if err != nil {
+		return fmt.Errorf("NIK %s: %w", u.NIK, err)
+	}
+	u.ProvinceCode = n.Province
+	u.RegencyCode = n.Regency
+	u.DistrictCode = n.District
+	u.BirthDate = &n.BirthDate
+	u.Gender = n.Gender
+	return nil
+}
+
+func (s *userServ) audit(ctx context.Context, tx *sql.Tx, userID, action string, changes map[string]model.FieldChange) error {
+	return writeAudit(ctx, s.auditRepo, tx, userID, action, changes)
+}
+
+// writeAudit records a change of userID by the actor of ctx.
+func writeAudit(ctx context.Context, repo repository.AuditRepository, tx *sql.Tx, userID, action string, changes map[string]model.FieldChange) error {
+	a := actorFrom(ctx)
+	err := repo.Create(ctx, tx, &model.AuditEntry{
+		UserID:   userID,
+		Action:   action,
+		Source:   a.Source,
+		Operator: a.Operator,
+		Changes:  changes,
+	})
+	if err != nil {
+		return fmt.Errorf("audit %s %s: %w", action, userID, err)
+	}
+	return nil
+}
+
+// applyEditable returns before with the form-editable fields taken from u.
+func applyEditable(before, u *model.User) *model.User {
+	after := *before
+	after.NIK, after.Status, after.Name = u.NIK, u.Status, u.Name
+	after.Phone, after.Address, after.Rating = u.Phone, u.Address, u.Rating
+	after.Notes, after.Photo = u.Notes, u.Photo
+	return &after
+}
+
+// checksum fingerprints photo content for the audit trail.
+func checksum(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *userServ) imageSequenceAction(ctx context.Context, u *model.User, imgByte []byte) error {
+	mime := util.GetMimeType(u.Photo)
 
 	// Upload user photo to storage
-	if err := s.storageClient.Upload(ctx, "images/"+u.ID+ext, mime, bytes.NewReader(imgByte)); err != nil {
+	if err := s.storageClient.Upload(ctx, photoKey(u), mime, bytes.NewReader(imgByte)); err != nil {
 		err = fmt.Errorf("upload photo: %w", err)
 		return err
 	}
 
+	if _, _, err := s.renderCard(ctx, u, imgByte); err != nil {
+		return err
+	}
+	if _, err := s.renderForm(ctx, u); err != nil {
+		return err
+	}
+
 	// --TO BE REMOVED LATER--
 	if err := s.excelSvc.UpdateExcel(u); err != nil {
 		err = fmt.Errorf("update excel: %w", err)
@@ -224,3 +755,202 @@ func (s *userServ) imageSequenceAction(ctx context.Context, u *model.User, imgBy
 
 	return nil
 }
+
+func (s *userServ) GetDocument(ctx context.Context, userID, docType string) ([]byte, string, error) {
+	var key, fileName string
+	switch docType {
+	case DocCard:
+		key, fileName = cardKey(userID), userID+".png"
+	case DocCardBack:
+		key, fileName = cardBackKey(userID), userID+"_back.png"
+	case DocCardPrint:
+		return s.printCard(ctx, userID)
+	case DocForm:
+		key, fileName = formKey(userID), userID+".pdf"
+	default:
+		return nil, "", fmt.Errorf("invalid document type: %s", docType)
+	}
+
+	data, err := s.storageClient.Download(ctx, key)
+	if err == nil {
+		return data, fileName, nil
+	}
+	if !errors.Is(err, config.ErrObjectNotFound) {
+		return nil, "", fmt.Errorf("download %s: %w", docType, err)
+	}
+
+	// Object is missing (older record or wiped bucket), regenerate it
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if docType == DocForm {
+		data, err = s.renderForm(ctx, u)
+		return data, fileName, err
+	}
+
+	photo, err := s.loadPhoto(ctx, u)
+	if err != nil {
+		return nil, "", err
+	}
+	front, back, err := s.renderCard(ctx, u, photo)
+	if docType == DocCardBack {
+		return back, fileName, err
+	}
+	return front, fileName, err
+}
+
+// printCard lays out both sides of the card of userID as a two-page PDF.
+// It is built on demand from the stored sides and not kept in storage.
+func (s *userServ) printCard(ctx context.Context, userID string) ([]byte, string, error) {
+	front, _, err := s.GetDocument(ctx, userID, DocCard)
+	if err != nil {
+		return nil, "", err
+	}
+	back, _, err := s.GetDocument(ctx, userID, DocCardBack)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := s.pdfSvc.PrintCard(front, back, &buf); err != nil {
+		return nil, "", fmt.Errorf("generate card print: %w", err)
+	}
+	return buf.Bytes(), userID + "_print.pdf", nil
+}
+
+func (s *userServ) GetDocumentURLs(ctx context.Context, userID string) (map[string]string, error) {
+	card, err := s.storageClient.PresignGet(ctx, cardKey(userID), util.PresignTTL)
+	if err != nil {
+		return nil, fmt.Errorf("presign card: %w", err)
+	}
+	back, err := s.storageClient.PresignGet(ctx, cardBackKey(userID), util.PresignTTL)
+	if err != nil {
+		return nil, fmt.Errorf("presign card back: %w", err)
+	}
+	form, err := s.storageClient.PresignGet(ctx, formKey(userID), util.PresignTTL)
+	if err != nil {
+		return nil, fmt.Errorf("presign form: %w", err)
+	}
+	return map[string]string{DocCard: card, DocCardBack: back, DocForm: form}, nil
+}
+
+// presignPhoto swaps the stored photo location of u for a short-lived URL.
+// Bundled assets such as the default avatar are served from /static instead.
+func (s *userServ) presignPhoto(ctx context.Context, u *model.User) error {
+	if strings.HasPrefix(u.Photo, util.PathToAssets) {
+		u.Photo = "/" + u.Photo
+		return nil
+	}
+	url, err := s.storageClient.PresignGet(ctx, photoKey(u), util.PresignTTL)
+	if err != nil {
+		return fmt.Errorf("presign photo: %w", err)
+	}
+	u.Photo = url
+	return nil
+}
+
+// renderCard draws both sides of the ID card as PNG and stores them under
+// cardKey and cardBackKey.
+func (s *userServ) renderCard(ctx context.Context, u *model.User, photo []byte) ([]byte, []byte, error) {
+	t, err := s.types.GetHolderType(ctx, u.Status)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := s.cardData(u, photo)
+	if err != nil {
+		return nil, nil, err
+	}
+	var front, back bytes.Buffer
+	if err := util.GenerateIDCard(t.CardTemplate, data, &front); err != nil {
+		return nil, nil, fmt.Errorf("generate ID card: %w", err)
+	}
+	if err := util.GenerateIDCard(t.BackTemplate, data, &back); err != nil {
+		return nil, nil, fmt.Errorf("generate ID card back: %w", err)
+	}
+	if err := s.storageClient.Upload(ctx, cardKey(u.ID), "image/png", bytes.NewReader(front.Bytes())); err != nil {
+		return nil, nil, fmt.Errorf("upload ID card: %w", err)
+	}
+	if err := s.storageClient.Upload(ctx, cardBackKey(u.ID), "image/png", bytes.NewReader(back.Bytes())); err != nil {
+		return nil, nil, fmt.Errorf("upload ID card back: %w", err)
+	}
+	return front.Bytes(), back.Bytes(), nil
+}
+
+// cardData is what card layouts can bind to.
+func (s *userServ) cardData(u *model.User, photo []byte) (*util.CardData, error) {
+	photoImg, _, err := image.Decode(bytes.NewReader(photo))
+	if err != nil {
+		return nil, fmt.Errorf("decode photo: %w", err)
+	}
+	var validUntil string
+	if u.ValidUntil != nil {
+		validUntil = "Berlaku s/d " + u.ValidUntil.Format(util.CardDateLayout)
+	}
+	return &util.CardData{
+		Text: map[string]string{
+			"name":        util.NormalizeName(u.Name),
+			"card_no":     s.ids.Display(u.ID),
+			"address":     u.Address,
+			"valid_until": validUntil,
+			"qr":          s.codes.QRContent(u.ID, u.CardIssue),
+			"contact":     s.back.EmergencyContact,
+		},
+		Lists:  map[string][]string{"rules": siteRules},
+		Images: map[string]image.Image{"photo": photoImg},
+	}, nil
+}
+
+// renderForm prints the registration PDF and stores it under formKey.
+func (s *userServ) renderForm(ctx context.Context, u *model.User) ([]byte, error) {
+	t, err := s.types.GetHolderType(ctx, u.Status)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := s.pdfSvc.PrintPDF(u, t.PDFForm, &buf); err != nil {
+		return nil, fmt.Errorf("generate PDF: %w", err)
+	}
+	if err := s.storageClient.Upload(ctx, formKey(u.ID), "application/pdf", bytes.NewReader(buf.Bytes())); err != nil {
+		return nil, fmt.Errorf("upload PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// loadPhoto fetches the stored photo of u, falling back to the default avatar.
+func (s *userServ) loadPhoto(ctx context.Context, u *model.User) ([]byte, error) {
+	photo, err := s.storageClient.Download(ctx, photoKey(u))
+	if errors.Is(err, config.ErrObjectNotFound) {
+		return os.ReadFile(avatarPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("download photo: %w", err)
+	}
+	return photo, nil
+}
+
+// photoKey is the storage key of the photo of u. Older rows keep a full
+// bucket URL in Photo, so only its extension is used.
+func photoKey(u *model.User) string {
+	return util.PathToUploads + u.ID + util.GetFileFormat(u.Photo)
+}
+
+func cardKey(userID string) string {
+	return util.PathToCard + userID + ".png"
+}
+
+func cardBackKey(userID string) string {
+	return util.PathToCard + userID + "_back.png"
+}
+
+func formKey(userID string) string {
+	return util.PathToContract + userID + ".pdf"
+}
+
+// newToken returns a random hex token for reservations.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"idcard/internal/model"
+	"time"
+)
+
+var ErrInvalidValidity = errors.New("invalid validity")
+
+// RenewUser issues a new card to an active holder: validity is extended by
+// the configured period from the current expiry, or from today once the
+// card has expired, and the card and form are rendered again. The QR code
+// of the previous card stops scanning as valid.
+func (s *userServ) RenewUser(ctx context.Context, userID string) (*model.User, error) {
+	tx, err := s.repo.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.repo.GetUserForUpdate(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !before.Active {
+		return nil, fmt.Errorf("user %s is inactive", userID)
+	}
+	if before.State == model.StateBlacklisted {
+		return nil, fmt.Errorf("user %s is blacklisted", userID)
+	}
+
+	now := time.Now()
+	from := dateOf(now)
+	if before.ValidUntil != nil && before.ValidUntil.After(from) {
+		from = *before.ValidUntil
+	}
+	after := *before
+	after.IssuedAt, after.CardIssue = &now, before.CardIssue+1
+	until := s.validity.Until(from)
+	after.ValidUntil = &until
+
+	if err := s.repo.Renew(ctx, tx, userID, now, until, after.CardIssue); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, tx, userID, model.AuditRenew, diffUser(before, &after)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	photo, err := s.loadPhoto(ctx, &after)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := s.renderCard(ctx, &after, photo); err != nil {
+		return nil, err
+	}
+	if _, err := s.renderForm(ctx, &after); err != nil {
+		return nil, err
+	}
+	s.annotate(&after, dateOf(now))
+	return &after, s.presignPhoto(ctx, &after)
+}
+
+// issueCard starts the validity period of the first card of u, printed at t.
+func (s *userServ) issueCard(u *model.User, t time.Time) {
+	until := s.validity.Until(t)
+	u.IssuedAt, u.ValidUntil, u.CardIssue = &t, &until, 1
+}
+
+// annotate fills the fields of u computed when it is read: validity flags
+// and the state in effect today.
+func (s *userServ) annotate(u *model.User, today time.Time) {
+	s.flagValidity(u, today)
+	u.State = effectiveState(u, today)
+}
+
+// flagValidity marks u as expired once today is past its valid until date,
+// or as expiring soon within the warning period before it.
+func (s *userServ) flagValidity(u *model.User, today time.Time) {
+	if u.ValidUntil == nil {
+		return
+	}
+	until := dateOf(*u.ValidUntil)
+	u.Expired = today.After(until)
+	u.ExpiringSoon = !u.Expired && !today.Before(until.AddDate(0, 0, -s.validity.WarningDays))
+}
+
+// dateOf is the calendar day of t as midnight UTC, the way dates are read
+// back from the database.
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -1,81 +1,99 @@
 package util
 
 import (
-	"bytes"
+	"errors"
+	"fmt"
 	"image"
-	"image/color"
 	"image/draw"
 	"image/png"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/skip2/go-qrcode"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
-	"golang.org/x/image/math/fixed"
 )
 
-func GenerateIDCard(templatePath, name, userID, alamat, outputPath string, photoFile *bytes.Reader) error {
-	roboto200 := pathToFont + "Roboto/static/Roboto-Light.ttf"
-	roboto400 := pathToFont + "Roboto/static/Roboto-Regular.ttf"
+// barcodeQuietZone is the blank margin, in bar modules, a scanner needs on
+// each side of a barcode.
+const barcodeQuietZone = 10
 
-	bgFile, err := os.Open(templatePath)
+// barcodeMinModule is the narrowest bar, in template pixels, that a cheap
+// 1D scanner reads reliably off a printed card. Card numbers too long for
+// it get bars of barcodeFallbackModule, and no barcode when even those do
+// not fit.
+const (
+	barcodeMinModule      = 3
+	barcodeFallbackModule = 2
+)
+
+// ErrBarcodeTooLong reports a barcode that does not fit its area even with
+// the narrower fallback bars.
+var ErrBarcodeTooLong = errors.New("barcode too long for its area")
+
+// GenerateIDCard draws one side of a card: the template with data laid out
+// by the definition next to it (see LayoutPath).
+func GenerateIDCard(templatePath string, data *CardData, out io.Writer) error {
+	layout, err := LoadCardLayout(templatePath)
 	if err != nil {
-		log.Print("template:", err)
+		log.Print("layout:", err)
 		return err
 	}
-	defer bgFile.Close()
-	bgImg, _, err := image.Decode(bgFile)
+	card, err := loadTemplate(templatePath)
 	if err != nil {
-		log.Print("background decoder:", err)
 		return err
 	}
-
-	photoImg, _, err := image.Decode(photoFile)
-	if err != nil {
-		log.Print("image decoder:", err)
+	if err := layout.Render(card, data); err != nil {
+		log.Print("drawer:", err)
 		return err
 	}
-	// TODO:Resize photo
-
-	card := image.NewRGBA(bgImg.Bounds())
-	draw.Draw(card, bgImg.Bounds(), bgImg, image.Point{}, draw.Src)
-
-	photoPosition := image.Rect(155, 320, 490, 770)
-	draw.Draw(card, photoPosition, photoImg, image.Point{}, draw.Over)
+	return png.Encode(out, card)
+}
 
-	err = drawText(card, name, 240, 818, 32, roboto400, color.Black)
+// loadTemplate decodes a card background into an image to draw on.
+func loadTemplate(templatePath string) (*image.RGBA, error) {
+	bgFile, err := os.Open(templatePath)
 	if err != nil {
-		log.Print("drawer:", err)
-		return err
+		log.Print("template:", err)
+		return nil, err
 	}
-	err = drawText(card, "SIK-"+userID, 240, 862, 28, roboto400, color.Black)
+	defer bgFile.Close()
+	bgImg, _, err := image.Decode(bgFile)
 	if err != nil {
-		log.Print("drawer:", err)
-		return err
+		log.Print("background decoder:", err)
+		return nil, err
 	}
+	card := image.NewRGBA(bgImg.Bounds())
+	draw.Draw(card, bgImg.Bounds(), bgImg, image.Point{}, draw.Src)
+	return card, nil
+}
 
-	arr := strings.SplitN(alamat, ",", 2)
-	_ = drawText(card, arr[0], 240, 910, 24, roboto200, color.Black)
-	if len(arr) == 2 {
-		_ = drawText(card, strings.TrimLeft(arr[1], " "), 240, 945, 24, roboto200, color.Black)
+// drawQR prints payload as a QR code filling the square area, quiet zone
+// included.
+func drawQR(img *image.RGBA, payload string, area image.Rectangle) error {
+	qr, err := qrcode.New(payload, qrcode.Medium)
+	if err != nil {
+		return err
 	}
-
-	outFile, _ := os.Create(outputPath)
-	defer outFile.Close()
-	return png.Encode(outFile, card)
+	draw.Draw(img, area, qr.Image(area.Dx()), image.Point{}, draw.Src)
+	return nil
 }
 
-func drawText(img *image.RGBA, text string, x, y int, fontSize float64, fontPath string, col color.Color) error {
+func loadFace(fontPath string, fontSize float64) (font.Face, error) {
 	// Load TTF font
 	fontBytes, err := os.ReadFile(fontPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	ft, err := opentype.Parse(fontBytes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	face, err := opentype.NewFace(ft, &opentype.FaceOptions{
@@ -85,18 +103,74 @@ func drawText(img *image.RGBA, text string, x, y int, fontSize float64, fontPath
 	})
 	if err != nil {
 		log.Print("font", err)
-		return err
+		return nil, err
 	}
-	defer face.Close()
+	return face, nil
+}
 
-	// Set up drawer
-	d := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(col),
-		Face: face,
-		Dot:  fixed.P(x, y),
+// barcodeFit encodes text as Code128 and picks the bar width it gets along
+// the longer side of area, or fails with ErrBarcodeTooLong.
+func barcodeFit(text string, area image.Rectangle) (barcode.Barcode, int, error) {
+	bc, err := code128.Encode(text)
+	if err != nil {
+		return nil, 0, err
+	}
+	length := max(area.Dx(), area.Dy())
+	modules := bc.Bounds().Dx()
+	factor := min(length/(modules+2*barcodeQuietZone), barcodeMinModule)
+	if factor < barcodeFallbackModule {
+		return nil, 0, fmt.Errorf("%w: %q has %d modules, %v needs to be %d pixels long", ErrBarcodeTooLong,
+			text, modules, area, (modules+2*barcodeQuietZone)*barcodeFallbackModule)
 	}
+	return bc, factor, nil
+}
+
+// drawBarcode prints text as Code128 centered in area on a white ground.
+// Bars run across the shorter side, so a tall area holds a rotated barcode.
+func drawBarcode(img *image.RGBA, text string, area image.Rectangle) error {
+	bc, factor, err := barcodeFit(text, area)
+	if err != nil {
+		return err
+	}
+	length, vertical := area.Dx(), area.Dy() > area.Dx()
+	if vertical {
+		length = area.Dy()
+	}
+	modules := bc.Bounds().Dx()
 
-	d.DrawString(text)
+	draw.Draw(img, area, image.White, image.Point{}, draw.Src)
+	start := (length - modules*factor) / 2
+	for m := range modules {
+		if r, _, _, _ := bc.At(m, 0).RGBA(); r != 0 {
+			continue
+		}
+		from, to := start+m*factor, start+(m+1)*factor
+		bar := image.Rect(area.Min.X+from, area.Min.Y, area.Min.X+to, area.Max.Y)
+		if vertical {
+			bar = image.Rect(area.Min.X, area.Min.Y+from, area.Max.X, area.Min.Y+to)
+		}
+		draw.Draw(img, bar, image.Black, image.Point{}, draw.Src)
+	}
 	return nil
 }
+
+// ParseArea reads a card area written as "x,y,width,height" in template
+// pixels. An empty string is the empty area.
+func ParseArea(s string) (image.Rectangle, error) {
+	if strings.TrimSpace(s) == "" {
+		return image.Rectangle{}, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("area %q must be x,y,width,height", s)
+	}
+	var n [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || v < 0 {
+			return image.Rectangle{}, fmt.Errorf("area %q must be x,y,width,height in pixels", s)
+		}
+		n[i] = v
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
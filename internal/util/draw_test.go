@@ -0,0 +1,76 @@
+package util_test
+
+import (
+	"errors"
+	"image"
+	"io"
+	"testing"
+
+	"idcard/internal/config"
+	"idcard/internal/util"
+)
+
+var cardTemplates = []string{"static/assets/kartu.png", "static/assets/BACK.png"}
+
+func TestLongestCardNoGetsBarcodeOnBothSides(t *testing.T) {
+	t.Chdir("../..")
+	schemes := []struct {
+		prefix string
+		ids    config.IDScheme
+	}{
+		{"S", config.IDScheme{Width: 3, DisplayPrefix: "SIK-"}},
+		{"S", config.IDScheme{Width: 3, Year: true, DisplayPrefix: "SIK-"}},
+		{"S", config.IDScheme{Width: 6, DisplayPrefix: "SIK-"}},
+		{"S", config.IDScheme{Width: 3, Site: "KDS", Year: true, DisplayPrefix: "SIK-"}},
+		{"TRK", config.IDScheme{Width: 6, Year: true, DisplayPrefix: "SIK-"}},
+		{"TRK", config.IDScheme{Width: 6, Site: "KDS", Year: true, DisplayPrefix: "SIK-"}},
+	}
+	for _, sc := range schemes {
+		cardNo := sc.ids.LongestCardNo(sc.prefix)
+		data := cardData(cardNo)
+		for _, path := range cardTemplates {
+			layout, err := util.LoadCardLayout(path)
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			if err := layout.CheckBarcodes(data); err != nil {
+				t.Errorf("%s, %s: barcode left off: %v", path, cardNo, err)
+			}
+			if err := util.GenerateIDCard(path, data, io.Discard); err != nil {
+				t.Errorf("%s, %s: %v", path, cardNo, err)
+			}
+		}
+	}
+}
+
+func TestTooLongBarcodeLeavesCardUsable(t *testing.T) {
+	t.Chdir("../..")
+	data := cardData("SIK-WWWWWWWWWWWWWWWWWWWWWWWW000000000")
+	for _, path := range cardTemplates {
+		layout, err := util.LoadCardLayout(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := layout.CheckBarcodes(data); !errors.Is(err, util.ErrBarcodeTooLong) {
+			t.Errorf("%s: got %v, want ErrBarcodeTooLong", path, err)
+		}
+		if err := util.GenerateIDCard(path, data, io.Discard); err != nil {
+			t.Errorf("%s: card failed over its barcode: %v", path, err)
+		}
+	}
+}
+
+func cardData(cardNo string) *util.CardData {
+	return &util.CardData{
+		Text: map[string]string{
+			"name":        "Slamet Riyadi",
+			"card_no":     cardNo,
+			"address":     "Jl. Kudus - Pati Km 14, Jekulo",
+			"valid_until": "Berlaku s/d 31-12-2027",
+			"qr":          cardNo,
+			"contact":     "0291 123456",
+		},
+		Lists:  map[string][]string{"rules": {"Kartu wajib ditunjukkan kepada Satpam."}},
+		Images: map[string]image.Image{"photo": image.NewRGBA(image.Rect(0, 0, 180, 240))},
+	}
+}
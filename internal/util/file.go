@@ -20,13 +20,7 @@ func ImageWriter(data []byte, dir, name, format string) (string, error) {
 	return webPath, nil
 }
 
-func ServeDownloadables(w http.ResponseWriter, r *http.Request, filePath, filename string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
+func ServeDownloadables(w http.ResponseWriter, r *http.Request, file io.ReadSeeker, filename string) error {
 	// Read first 512 bytes to detect MIME type
 	buf := make([]byte, 512)
 	n, err := file.Read(buf)
@@ -56,3 +50,13 @@ func GetFileFormat(filePath string) string {
 func GetMimeType(filePath string) string {
 	return mime.TypeByExtension(GetFileFormat(filePath))
 }
+
+// PublicURL turns a stored photo location into a browser URL. Locations
+// saved as bare host paths (bucket CDN) get https, while absolute URLs and
+// paths served by this app are returned unchanged.
+func PublicURL(location string) string {
+	if location == "" || strings.HasPrefix(location, "/") || strings.Contains(location, "://") {
+		return location
+	}
+	return "https://" + location
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// nameAliases folds common spelling variants and abbreviations of Indonesian
+// given names to one token, so "Mochammad", "Muh." and "M" all match.
+var nameAliases = map[string]string{
+	"md":        "m",
+	"mhd":       "m",
+	"moh":       "m",
+	"moch":      "m",
+	"much":      "m",
+	"muh":       "m",
+	"muhd":      "m",
+	"mochamad":  "m",
+	"mochammad": "m",
+	"mohamad":   "m",
+	"mohammad":  "m",
+	"mohammed":  "m",
+	"muchammad": "m",
+	"muhamad":   "m",
+	"muhammad":  "m",
+	"muhammed":  "m",
+	"sitti":     "siti",
+	"sity":      "siti",
+	"st":        "siti",
+}
+
+// oldSpelling maps pre-1972 Indonesian spelling to the current one
+// (Soekarno/Sukarno, Djoko/Joko).
+var oldSpelling = strings.NewReplacer("oe", "u", "dj", "j", "tj", "c", "sj", "sy", "nj", "ny")
+
+// NameKey is the search form of a name: lower case letters and spaces only,
+// old spellings modernised and given-name variants folded.
+func NameKey(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r):
+			return unicode.ToLower(r)
+		case unicode.IsSpace(r), r == '.', r == ',', r == '-':
+			return ' '
+		default:
+			return -1
+		}
+	}, name)
+
+	words := strings.Fields(clean)
+	for i, w := range words {
+		if alias, ok := nameAliases[w]; ok {
+			words[i] = alias
+			continue
+		}
+		words[i] = oldSpelling.Replace(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// addressAliases expands common abbreviations in Indonesian addresses.
+var addressAliases = map[string]string{
+	"jl":  "jalan",
+	"jln": "jalan",
+	"gg":  "gang",
+	"kp":  "kampung",
+	"kmp": "kampung",
+	"ds":  "desa",
+	"kel": "kelurahan",
+	"kec": "kecamatan",
+	"kab": "kabupaten",
+	"no":  "nomor",
+}
+
+// AddressKey is the comparison form of an address: lower case letters,
+// digits and spaces with common abbreviations expanded.
+func AddressKey(addr string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		default:
+			return ' '
+		}
+	}, addr)
+
+	words := strings.Fields(clean)
+	for i, w := range words {
+		if alias, ok := addressAliases[w]; ok {
+			words[i] = alias
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// trigrams splits s into the padded word trigrams pg_trgm uses.
+func trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, w := range strings.Fields(s) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// TrigramSimilarity is the share of trigrams a and b have in common, like
+// pg_trgm similarity(). Unlike Similarity a shared common word such as
+// "jalan" does not carry the score on its own.
+func TrigramSimilarity(a, b string) float64 {
+	return jaccard(trigrams(a), trigrams(b))
+}
+
+// Similarity scores how well query matches key between 0 and 1. It takes the
+// better of the whole-text trigram similarity and the average best match of
+// each query word, so a partial name ranks its holder high. Both arguments
+// must already be keys from NameKey or AddressKey.
+func Similarity(query, key string) float64 {
+	best := jaccard(trigrams(query), trigrams(key))
+
+	qWords, kWords := strings.Fields(query), strings.Fields(key)
+	if len(qWords) == 0 || len(kWords) == 0 {
+		return best
+	}
+	total := 0.0
+	for _, q := range qWords {
+		qt := trigrams(q)
+		wordBest := 0.0
+		for _, k := range kWords {
+			wordBest = max(wordBest, jaccard(qt, trigrams(k)))
+		}
+		total += wordBest
+	}
+	return max(best, total/float64(len(qWords)))
+}
@@ -0,0 +1,330 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// CardLayoutVersion is the version of the layout definition format read by
+// this renderer. Definitions of any other version are refused rather than
+// drawn wrong.
+const CardLayoutVersion = 1
+
+const (
+	FieldFill    = "fill"
+	FieldText    = "text"
+	FieldList    = "list"
+	FieldImage   = "image"
+	FieldQR      = "qr"
+	FieldBarcode = "barcode"
+)
+
+type (
+	// CardLayout describes what is drawn on one card template and where. It
+	// is read from a JSON file next to the template, so a redesigned card is
+	// a new template and definition and several can be used side by side.
+	CardLayout struct {
+		Version int         `json:"version"`
+		Fields  []CardField `json:"fields"`
+	}
+
+	// CardField is one element of a layout, drawn in order over the
+	// template. Its content is the literal Text or the card data named by
+	// Bind; fields whose content is empty, or whose When names empty data,
+	// are left off. Rect is "x,y,width,height" as for ParseArea.
+	//
+	// Text is drawn with its baseline at Y and aligned left, center or
+	// right of X. It is wrapped to Width when set, and split into separate
+	// lines at Split, at most MaxLines of them. A list numbers each item at
+	// X in NumberFont, with the item wrapped at X+Indent, Gap apart.
+	CardField struct {
+		Type       string  `json:"type"`
+		Bind       string  `json:"bind,omitempty"`
+		Text       string  `json:"text,omitempty"`
+		When       string  `json:"when,omitempty"`
+		Rect       string  `json:"rect,omitempty"`
+		X          int     `json:"x,omitempty"`
+		Y          int     `json:"y,omitempty"`
+		Width      int     `json:"width,omitempty"`
+		Font       string  `json:"font,omitempty"`
+		NumberFont string  `json:"number_font,omitempty"`
+		Size       float64 `json:"size,omitempty"`
+		LineHeight int     `json:"line_height,omitempty"`
+		Color      string  `json:"color,omitempty"`
+		Align      string  `json:"align,omitempty"`
+		Split      string  `json:"split,omitempty"`
+		MaxLines   int     `json:"max_lines,omitempty"`
+		Indent     int     `json:"indent,omitempty"`
+		Gap        int     `json:"gap,omitempty"`
+	}
+
+	// CardData is what the bindings of a layout refer to.
+	CardData struct {
+		Text   map[string]string
+		Lists  map[string][]string
+		Images map[string]image.Image
+	}
+)
+
+// LayoutPath is where the layout of a card template is defined: the
+// template path with a .json extension.
+func LayoutPath(templatePath string) string {
+	return strings.TrimSuffix(templatePath, filepath.Ext(templatePath)) + ".json"
+}
+
+// LoadCardLayout reads and checks the layout of templatePath, whose areas
+// must fall within the template.
+func LoadCardLayout(templatePath string) (*CardLayout, error) {
+	path := LayoutPath(templatePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l CardLayout
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("layout %s: %w", path, err)
+	}
+	if l.Version != CardLayoutVersion {
+		return nil, fmt.Errorf("layout %s: unsupported version %d, want %d", path, l.Version, CardLayoutVersion)
+	}
+	bounds, err := templateBounds(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range l.Fields {
+		if err := f.check(bounds); err != nil {
+			return nil, fmt.Errorf("layout %s: field %d (%s): %w", path, i+1, f.Type, err)
+		}
+	}
+	return &l, nil
+}
+
+// templateBounds is the size of a card template without decoding it.
+func templateBounds(templatePath string) (image.Rectangle, error) {
+	f, err := os.Open(templatePath)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("card template %s: %w", templatePath, err)
+	}
+	return image.Rect(0, 0, cfg.Width, cfg.Height), nil
+}
+
+func (f *CardField) check(bounds image.Rectangle) error {
+	switch f.Type {
+	case FieldFill, FieldImage, FieldQR, FieldBarcode:
+		area, err := ParseArea(f.Rect)
+		if err != nil {
+			return err
+		}
+		if area.Empty() {
+			return errors.New("rect is required")
+		}
+		if !area.In(bounds) {
+			return fmt.Errorf("rect %s falls outside the %dx%d template", f.Rect, bounds.Dx(), bounds.Dy())
+		}
+	case FieldText, FieldList:
+		if f.Size <= 0 {
+			return errors.New("size must be positive")
+		}
+		fonts := []string{f.Font}
+		if f.NumberFont != "" {
+			fonts = append(fonts, f.NumberFont)
+		}
+		for _, name := range fonts {
+			if _, err := os.Stat(pathToFont + name); name == "" || err != nil {
+				return fmt.Errorf("font %q not found", name)
+			}
+		}
+		switch f.Align {
+		case "", "left", "center", "right":
+		default:
+			return fmt.Errorf("unknown align %q", f.Align)
+		}
+	default:
+		return fmt.Errorf("unknown field type %q", f.Type)
+	}
+	if f.Type != FieldFill && f.Text == "" && f.Bind == "" {
+		return errors.New("text or bind is required")
+	}
+	_, err := parseColor(f.Color, color.Black)
+	return err
+}
+
+// Render draws the fields of l on card.
+func (l *CardLayout) Render(card *image.RGBA, data *CardData) error {
+	for i, f := range l.Fields {
+		if f.When != "" && data.Text[f.When] == "" && len(data.Lists[f.When]) == 0 {
+			continue
+		}
+		if err := f.draw(card, data); err != nil {
+			return fmt.Errorf("field %d (%s): %w", i+1, f.Type, err)
+		}
+	}
+	return nil
+}
+
+// CheckBarcodes fails when a barcode of l is too long for its area with
+// data, which would leave it off the card.
+func (l *CardLayout) CheckBarcodes(data *CardData) error {
+	for i, f := range l.Fields {
+		content := f.Text
+		if content == "" {
+			content = data.Text[f.Bind]
+		}
+		if f.Type != FieldBarcode || content == "" {
+			continue
+		}
+		area, _ := ParseArea(f.Rect)
+		if _, _, err := barcodeFit(content, area); err != nil {
+			return fmt.Errorf("field %d (%s): %w", i+1, f.Type, err)
+		}
+	}
+	return nil
+}
+
+func (f *CardField) draw(card *image.RGBA, data *CardData) error {
+	area, _ := ParseArea(f.Rect)
+	content := f.Text
+	if content == "" {
+		content = data.Text[f.Bind]
+	}
+
+	switch f.Type {
+	case FieldFill:
+		col, _ := parseColor(f.Color, color.White)
+		draw.Draw(card, area, image.NewUniform(col), image.Point{}, draw.Src)
+	case FieldImage:
+		// TODO:Resize photo
+		if img := data.Images[f.Bind]; img != nil {
+			draw.Draw(card, area, img, image.Point{}, draw.Over)
+		}
+	case FieldQR:
+		if content != "" {
+			return drawQR(card, content, area)
+		}
+	case FieldBarcode:
+		if content == "" {
+			return nil
+		}
+		// A card number too long for the area leaves the barcode off; the
+		// card still carries the printed number and the QR code
+		err := drawBarcode(card, content, area)
+		if errors.Is(err, ErrBarcodeTooLong) {
+			log.Print("barcode left off the card: ", err)
+			return nil
+		}
+		return err
+	case FieldText:
+		if content == "" {
+			return nil
+		}
+		lines := []string{content}
+		if f.Split != "" {
+			n := f.MaxLines
+			if n == 0 {
+				n = -1
+			}
+			lines = strings.SplitN(content, f.Split, n)
+		}
+		_, err := f.drawLines(card, f.Font, f.X, f.Y, f.Width, lines)
+		return err
+	case FieldList:
+		y := f.Y
+		for i, item := range data.Lists[f.Bind] {
+			if _, err := f.drawLines(card, f.NumberFont, f.X, y, 0, []string{strconv.Itoa(i+1) + "."}); err != nil {
+				return err
+			}
+			next, err := f.drawLines(card, f.Font, f.X+f.Indent, y, f.Width, []string{item})
+			if err != nil {
+				return err
+			}
+			y = next + f.Gap
+		}
+	}
+	return nil
+}
+
+// drawLines draws each line in fontName, wrapped to width when it is set,
+// the first baseline at y, and returns the baseline after the last line.
+func (f *CardField) drawLines(card *image.RGBA, fontName string, x, y, width int, lines []string) (int, error) {
+	if fontName == "" {
+		fontName = f.Font
+	}
+	face, err := loadFace(pathToFont+fontName, f.Size)
+	if err != nil {
+		return y, err
+	}
+	defer face.Close()
+
+	col, _ := parseColor(f.Color, color.Black)
+	d := &font.Drawer{Dst: card, Src: image.NewUniform(col), Face: face}
+	lineHeight := f.LineHeight
+	if lineHeight == 0 {
+		lineHeight = int(f.Size * 1.3)
+	}
+	for _, line := range lines {
+		wrapped := []string{strings.TrimSpace(line)}
+		if width > 0 {
+			wrapped = wrapText(d, line, width)
+		}
+		for _, w := range wrapped {
+			dx := 0
+			switch f.Align {
+			case "center":
+				dx = d.MeasureString(w).Ceil() / 2
+			case "right":
+				dx = d.MeasureString(w).Ceil()
+			}
+			d.Dot = fixed.P(x-dx, y)
+			d.DrawString(w)
+			y += lineHeight
+		}
+	}
+	return y, nil
+}
+
+// wrapText breaks text between words into lines no wider than width.
+func wrapText(d *font.Drawer, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		next := strings.TrimSpace(line + " " + word)
+		if line != "" && d.MeasureString(next).Ceil() > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseColor reads a #RRGGBB color, or returns fallback for an empty one.
+func parseColor(s string, fallback color.Color) (color.Color, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 || s[0] != '#' {
+		return fallback, fmt.Errorf("invalid color %q, want #RRGGBB", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
@@ -0,0 +1,139 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	GenderMale   = "L"
+	GenderFemale = "P"
+)
+
+var ErrInvalidNIK = errors.New("invalid NIK")
+
+// Provinces maps the two-digit province code at the start of a NIK to its name.
+var Provinces = map[string]string{
+	"11": "Aceh",
+	"12": "Sumatera Utara",
+	"13": "Sumatera Barat",
+	"14": "Riau",
+	"15": "Jambi",
+	"16": "Sumatera Selatan",
+	"17": "Bengkulu",
+	"18": "Lampung",
+	"19": "Kepulauan Bangka Belitung",
+	"21": "Kepulauan Riau",
+	"31": "DKI Jakarta",
+	"32": "Jawa Barat",
+	"33": "Jawa Tengah",
+	"34": "DI Yogyakarta",
+	"35": "Jawa Timur",
+	"36": "Banten",
+	"51": "Bali",
+	"52": "Nusa Tenggara Barat",
+	"53": "Nusa Tenggara Timur",
+	"61": "Kalimantan Barat",
+	"62": "Kalimantan Tengah",
+	"63": "Kalimantan Selatan",
+	"64": "Kalimantan Timur",
+	"65": "Kalimantan Utara",
+	"71": "Sulawesi Utara",
+	"72": "Sulawesi Tengah",
+	"73": "Sulawesi Selatan",
+	"74": "Sulawesi Tenggara",
+	"75": "Gorontalo",
+	"76": "Sulawesi Barat",
+	"81": "Maluku",
+	"82": "Maluku Utara",
+	"91": "Papua",
+	"92": "Papua Barat",
+	"93": "Papua Selatan",
+	"94": "Papua Tengah",
+	"95": "Papua Pegunungan",
+	"96": "Papua Barat Daya",
+}
+
+type (
+	// NIK is a decoded Nomor Induk Kependudukan:
+	// PP KK CC DDMMYY SSSS, where women have 40 added to the birth day.
+	NIK struct {
+		Province  string
+		Regency   string
+		District  string
+		BirthDate time.Time
+		Gender    string
+		Serial    string
+	}
+)
+
+// ParseNIK validates s and decodes its region, birth date and gender.
+// Two-digit years resolve to the latest century that is not after now.
+func ParseNIK(s string, now time.Time) (*NIK, error) {
+	if len(s) != 16 {
+		return nil, fmt.Errorf("%w: must be 16 digits", ErrInvalidNIK)
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("%w: must be 16 digits", ErrInvalidNIK)
+		}
+	}
+
+	n := &NIK{Province: s[0:2], Regency: s[0:4], District: s[0:6], Serial: s[12:16], Gender: GenderMale}
+	if _, ok := Provinces[n.Province]; !ok {
+		return nil, fmt.Errorf("%w: unknown province code %s", ErrInvalidNIK, n.Province)
+	}
+	if s[2:4] == "00" {
+		return nil, fmt.Errorf("%w: regency code is 00", ErrInvalidNIK)
+	}
+	if s[4:6] == "00" {
+		return nil, fmt.Errorf("%w: district code is 00", ErrInvalidNIK)
+	}
+	if n.Serial == "0000" {
+		return nil, fmt.Errorf("%w: serial is 0000", ErrInvalidNIK)
+	}
+
+	day, month, year := ParseInt(s[6:8]), ParseInt(s[8:10]), ParseInt(s[10:12])
+	if day > 40 {
+		day -= 40
+		n.Gender = GenderFemale
+	}
+	year += now.Year() / 100 * 100
+	if year > now.Year() {
+		year -= 100
+	}
+	n.BirthDate = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalises overflow, so a changed day or month means it did not exist
+	if day < 1 || month < 1 || month > 12 || n.BirthDate.Day() != day || int(n.BirthDate.Month()) != month {
+		return nil, fmt.Errorf("%w: birth date %s is not a date", ErrInvalidNIK, s[6:12])
+	}
+	if n.BirthDate.After(now) {
+		return nil, fmt.Errorf("%w: birth date is in the future", ErrInvalidNIK)
+	}
+	return n, nil
+}
+
+// Age is the age in whole years at now of someone born on birth.
+func Age(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+// NIKDistance counts the digits that differ between two NIKs, so a mistyped
+// NIK of the same person is 1 or 2 away. NIKs of different length are 16 apart.
+func NIKDistance(a, b string) int {
+	if len(a) != len(b) {
+		return 16
+	}
+	d := 0
+	for i := range len(a) {
+		if a[i] != b[i] {
+			d++
+		}
+	}
+	return d
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone turns an Indonesian mobile number written as 08xx, +628xx,
+// 628xx or 8xx, with or without spaces, dashes, dots and brackets, into
+// E.164 (+628xx). Mobile numbers have 9 to 12 digits after the country code.
+func NormalizePhone(s string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+			return -1
+		default:
+			return 'x'
+		}
+	}, strings.TrimPrefix(strings.TrimSpace(s), "+"))
+	if strings.Contains(digits, "x") {
+		return "", fmt.Errorf("%w: %q contains letters or symbols", ErrInvalidPhone, s)
+	}
+
+	switch {
+	case strings.HasPrefix(digits, "62"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+	if !strings.HasPrefix(digits, "8") {
+		return "", fmt.Errorf("%w: %q is not an Indonesian mobile number (08xx)", ErrInvalidPhone, s)
+	}
+	if len(digits) < 9 || len(digits) > 12 {
+		return "", fmt.Errorf("%w: %q must have 10 to 13 digits starting with 08", ErrInvalidPhone, s)
+	}
+	return "+62" + digits, nil
+}
+
+// LocalPhone formats an E.164 Indonesian number the way people write it,
+// 0812-3456-7890. Anything else is returned unchanged.
+func LocalPhone(e164 string) string {
+	rest, ok := strings.CutPrefix(e164, "+62")
+	if !ok || rest == "" {
+		return e164
+	}
+	local := "0" + rest
+	if len(local) <= 8 {
+		return local
+	}
+	return local[:4] + "-" + local[4:8] + "-" + local[8:]
+}
+
+// PhoneSearchKey is the part of a typed phone number, whole or partial,
+// found inside the stored E.164 form: its digits without the leading 0, 62
+// or +62. It is empty when s is not a phone number.
+func PhoneSearchKey(s string) string {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+			return -1
+		default:
+			return 'x'
+		}
+	}, strings.TrimPrefix(strings.TrimSpace(s), "+"))
+	if digits == "" || strings.Contains(digits, "x") {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(digits, "62"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+	return digits
+}
@@ -10,8 +10,8 @@ import (
 const (
 	PathToAssets   string = "static/assets/"
 	PathToUploads  string = "uploads/"
-	PathToCard     string = "tmp/idcards/"
-	PathToContract string = "tmp/contracts/"
+	PathToCard     string = "idcards/"
+	PathToContract string = "contracts/"
 
 	pathToFont string = PathToAssets + "fonts/"
 )
@@ -47,6 +47,17 @@ func NormalizeName(nama string) string {
 	return res[:maxChar]
 }
 
+// MaskName keeps the first letter of each word of name and stars out the
+// rest, so a holder can be recognised without publishing the full name.
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = strings.ToUpper(string(r[0])) + strings.Repeat("*", len(r)-1)
+	}
+	return strings.Join(words, " ")
+}
+
 func CompletionCheck(m map[string]string) (bool, string) {
 	check := false
 	for key, value := range m {
@@ -5,3 +5,12 @@ import "time"
 const Timeout = 30 * time.Second
 const ConMaxLifeTime = 50 * time.Minute
 const ConIdleTime = 5 * time.Minute
+
+// PresignTTL is how long links to stored photos and documents stay valid
+const PresignTTL = 15 * time.Minute
+
+// ReservationTTL is how long a user ID shown on the form stays held
+const ReservationTTL = 15 * time.Minute
+
+// CardDateLayout is how dates are printed on cards and forms
+const CardDateLayout = "02-01-2006"
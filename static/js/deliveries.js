@@ -0,0 +1,33 @@
+// A card scanner types the number into the focused card field and presses
+// Enter, which moves on to the vehicle instead of submitting the form
+document.addEventListener("DOMContentLoaded", () => {
+  const form = document.getElementById("deliveryForm");
+  const inspector = document.getElementById("inspector");
+  inspector.value = localStorage.getItem("operator") || "";
+
+  form.card.addEventListener("keydown", (event) => {
+    if (event.key === "Enter") {
+      event.preventDefault();
+      form.vehicle.focus();
+    }
+  });
+
+  form.addEventListener("submit", (event) => {
+    event.preventDefault();
+    const body = new URLSearchParams(new FormData(form));
+    body.set("operator", localStorage.getItem("operator") || inspector.value);
+    fetch("/deliveries/add", { method: "POST", body: body })
+      .then((res) => res.json())
+      .then((data) => {
+        if (data.Error) {
+          document.getElementById("warning").textContent = "⚠️ " + data.Error;
+          form.card.select();
+        } else {
+          window.location.reload();
+        }
+      })
+      .catch((err) => {
+        console.error("Error adding delivery:", err);
+      });
+  });
+});
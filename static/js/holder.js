@@ -0,0 +1,24 @@
+// The inspector defaults to the operator remembered by the main page
+document.addEventListener("DOMContentLoaded", () => {
+  const form = document.getElementById("inspectionForm");
+  const inspector = document.getElementById("inspector");
+  inspector.value = localStorage.getItem("operator") || "";
+
+  form.addEventListener("submit", (event) => {
+    event.preventDefault();
+    const body = new URLSearchParams(new FormData(form));
+    body.set("operator", localStorage.getItem("operator") || inspector.value);
+    fetch("/inspections/add", { method: "POST", body: body })
+      .then((res) => res.json())
+      .then((data) => {
+        if (data.Error) {
+          document.getElementById("warning").textContent = "⚠️ " + data.Error;
+        } else {
+          window.location.reload();
+        }
+      })
+      .catch((err) => {
+        console.error("Error adding inspection:", err);
+      });
+  });
+});
@@ -0,0 +1,40 @@
+document.addEventListener("DOMContentLoaded", () => {
+  const form = document.getElementById("holderTypeForm");
+  form.addEventListener("submit", (event) => {
+    event.preventDefault();
+    postHolderType("/holder-types/save", new URLSearchParams(new FormData(form)));
+  });
+});
+
+// editHolderType loads a row into the form; the code of a type cannot change
+function editHolderType(t) {
+  const form = document.getElementById("holderTypeForm");
+  form.mode.value = "update";
+  form.code.value = t.Code;
+  form.code.readOnly = true;
+  form.label.value = t.Label;
+  form.id_prefix.value = t.IDPrefix;
+  form.card_template.value = t.CardTemplate;
+  form.back_template.value = t.BackTemplate;
+  form.pdf_form.value = t.PDFForm;
+}
+
+function deleteHolderType(code) {
+  if (!confirm("Hapus jenis " + code + "?")) return;
+  postHolderType("/holder-types/delete", new URLSearchParams({ code: code }));
+}
+
+function postHolderType(url, body) {
+  fetch(url, { method: "POST", body: body })
+    .then((res) => res.json())
+    .then((data) => {
+      if (data.Error) {
+        document.getElementById("warning").textContent = "⚠️ " + data.Error;
+      } else {
+        window.location.reload();
+      }
+    })
+    .catch((err) => {
+      console.error("Error calling " + url + ":", err);
+    });
+}
@@ -12,20 +12,83 @@ navigator.mediaDevices
 let camPlayed = false;
 let statusVal = ""
 
+let duplicates = [];
+
+// Remember the operator name between visits for the audit trail
+document.addEventListener("DOMContentLoaded", () => {
+  const operator = document.getElementById("operator");
+  if (!operator) return;
+  operator.value = localStorage.getItem("operator") || "";
+  operator.addEventListener("change", () =>
+    localStorage.setItem("operator", operator.value)
+  );
+});
+
+// Reserve a new ID once the form is first used rather than on every
+// page view
+document.addEventListener("DOMContentLoaded", () => {
+  const form = document.getElementById("userForm");
+  if (!form) return;
+  form.addEventListener(
+    "focusin",
+    () => {
+      if (document.getElementById("userIdInput").value === "") getUserIDbyStatus();
+    },
+    { once: true }
+  );
+});
+
+// Check for possible duplicates while a new holder is typed in and ask
+// before saving one
+document.addEventListener("DOMContentLoaded", () => {
+  const form = document.getElementById("userForm");
+  if (!form) return;
+  ["nik", "name", "phone", "address"].forEach((name) =>
+    form.querySelector(`input[name="${name}"]`).addEventListener("blur", checkDuplicates)
+  );
+  form.addEventListener("submit", (event) => {
+    if (!form.getAttribute("action").endsWith("/create") || duplicates.length === 0) return;
+    if (!confirm("Kemungkinan data ganda: " + describeDuplicates(duplicates) + ". Tetap simpan?")) {
+      event.preventDefault();
+    }
+  });
+});
+
+// checkDuplicates asks the server for holders matching the form values
+function checkDuplicates() {
+  const form = document.getElementById("userForm");
+  if (!form.getAttribute("action").endsWith("/create")) return;
+  const params = new URLSearchParams({ id: document.getElementById("userIdInput").value });
+  ["nik", "name", "phone", "address"].forEach((name) =>
+    params.set(name, form.querySelector(`input[name="${name}"]`).value.trim())
+  );
+
+  fetch(`/duplicates?${params}`)
+    .then((res) => res.json())
+    .then((data) => {
+      duplicates = data.Data || [];
+      if (duplicates.length > 0) {
+        showWarning("⚠️ Kemungkinan data ganda: " + describeDuplicates(duplicates));
+      }
+    })
+    .catch((err) => {
+      console.error("Error checking duplicates:", err);
+    });
+}
+
+const duplicateReasons = { nik: "NIK mirip", phone: "HP sama", name_address: "nama & alamat mirip" };
+
+function describeDuplicates(candidates) {
+  return candidates
+    .map((c) => `${c.ID} ${c.Name} (${c.Reasons.map((r) => duplicateReasons[r] || r).join(", ")})`)
+    .join("; ");
+}
+
 // getUserbyNik get user detail by NIK inputed
 // update action and method to /update if user exist
 function getUserbyNik() {
   const nik = document.getElementById("nik").value;
-  const userStatus = document.getElementById("dropdown").value;
-  switch (userStatus) {
-    case "V":
-      statusVal = "Vendor"
-      break;
-
-    default:
-      statusVal = "Penyetor"
-      break;
-  }
+  statusVal = selectedStatusLabel();
 
   if (nik.trim() === "") return;
 
@@ -45,13 +108,24 @@ function getUserbyNik() {
         document.querySelector('input[name="userIdInput"]').value =
           user.ID || "";
         document.querySelector('input[name="name"]').value = user.Name || "";
-        document.querySelector('input[name="phone"]').value = user.Phone || "";
+        document.querySelector('input[name="phone"]').value = localPhone(user.Phone);
         document.querySelector('input[name="address"]').value =
           user.Address || "";
         document.querySelector('input[name="rating"]').value =
           user.Rating || "";
+        // QC inspections own the rating once there are any
+        document.querySelector('input[name="rating"]').readOnly = !!user.RatedAt;
         document.querySelector('input[name="notes"]').value = user.Notes || "";
         loadCanvas(user.Photo)
+        if (data.Warning) {
+          showWarning("⛔ " + data.Warning);
+        } else if (!user.Active) {
+          showWarning("⚠️ Data nonaktif: " + (user.DeleteReason || "-"));
+        } else if (user.Expired) {
+          showWarning("⚠️ Kartu kedaluwarsa sejak " + localDate(user.ValidUntil));
+        } else if (user.ExpiringSoon) {
+          showWarning("⚠️ Kartu berlaku s/d " + localDate(user.ValidUntil));
+        }
 
         form.setAttribute("action", "/update");
         formBtn.textContent = "Update " + statusVal;
@@ -62,19 +136,18 @@ function getUserbyNik() {
     });
 }
 
+// selectedStatusLabel is the label of the holder type picked in the form
+function selectedStatusLabel() {
+  const dropdown = document.getElementById("dropdown");
+  return dropdown.options[dropdown.selectedIndex].text;
+}
+
 // getUserIDbyStatus get current userID by status value
 function getUserIDbyStatus() {
   const userStatus = document.getElementById("dropdown").value;
-  switch (userStatus) {
-    case "V":
-      statusVal = "Vendor"
-      break;
-    default:
-      statusVal = "Penyetor"
-      break;
-  }
+  statusVal = selectedStatusLabel();
 
-  fetch(`/get-id?status=${encodeURIComponent(userStatus)}`)
+  fetch("/get-id", { method: "POST", body: new URLSearchParams({ status: userStatus }) })
     .then((res) => res.json())
     .then((data) => {
 
@@ -87,6 +160,7 @@ function getUserIDbyStatus() {
         clearWarning();
         document.getElementById("userId").textContent = userID;
         document.getElementById("userIdInput").value = userID;
+        document.getElementById("reservation").value = data.Reservation;
         document.getElementById("formSubmit").textContent = "Simpan " + statusVal
       }
     })
@@ -130,7 +204,7 @@ function loadCanvas(imgSrc) {
   const img = new Image();
 
   img.crossOrigin = "anonymous";
-  img.src = "https://"+imgSrc;
+  img.src = publicURL(imgSrc);
   img.onload = function () {
     ctx.clearRect(0, 0, canvas.width, canvas.height);
     ctx.drawImage(img, 0, 0, canvas.width, canvas.height);
@@ -151,6 +225,33 @@ function loadCanvas(imgSrc) {
   document.getElementById("video").style.display = "none";
 }
 
+// publicURL mirrors util.PublicURL: bucket paths get https, app paths stay as-is
+function publicURL(location) {
+  if (!location || location.startsWith("/") || location.includes("://")) {
+    return location;
+  }
+  return "https://" + location;
+}
+
+// localPhone mirrors util.LocalPhone: +6281234567890 -> 0812-3456-7890
+function localPhone(phone) {
+  if (!phone || !phone.startsWith("+62")) {
+    return phone || "";
+  }
+  const local = "0" + phone.slice(3);
+  if (local.length <= 8) {
+    return local;
+  }
+  return local.slice(0, 4) + "-" + local.slice(4, 8) + "-" + local.slice(8);
+}
+
+// localDate formats an API date as on the card: 2006-01-02T... -> 02-01-2006
+function localDate(value) {
+  if (!value) return "-";
+  const [y, m, d] = value.slice(0, 10).split("-");
+  return `${d}-${m}-${y}`;
+}
+
 function showWarning(message) {
   document.getElementById("warning").style.display = "block";
   document.getElementById("warning").style.position = "absolute";
@@ -166,6 +267,67 @@ function closePopup() {
   document.getElementById("popup").style.display = "none";
 }
 
+// postUserAction sends a form POST to a JSON endpoint and reloads on success
+function postUserAction(url, params) {
+  params.operator = localStorage.getItem("operator") || "";
+  fetch(url, { method: "POST", body: new URLSearchParams(params) })
+    .then((res) => res.json())
+    .then((data) => {
+      if (data.Error) {
+        showWarning("⚠️ " + data.Error);
+      } else {
+        window.location.reload();
+      }
+    })
+    .catch((err) => {
+      console.error("Error calling " + url + ":", err);
+    });
+}
+
+function deactivateUser(userID) {
+  const reason = prompt("Alasan menonaktifkan " + userID + ":");
+  if (!reason) return;
+  postUserAction("/delete", { uid: userID, reason: reason });
+}
+
+function restoreUser(userID) {
+  postUserAction("/restore", { uid: userID });
+}
+
+function deleteUser(userID) {
+  if (!confirm("Hapus permanen " + userID + " beserta foto, kartu dan formulir?")) return;
+  postUserAction("/delete", { uid: userID, hard: "true" });
+}
+
+function mergeUser(userID) {
+  const keepID = prompt("Gabungkan " + userID + " sebagai data ganda dari ID:");
+  if (!keepID) return;
+  if (!confirm(`Riwayat ${userID} dipindah ke ${keepID} dan kartu ${userID} dinonaktifkan. Lanjutkan?`)) return;
+  postUserAction("/merge", { keep: keepID.trim(), retire: userID });
+}
+
+function renewUser(userID) {
+  if (!confirm("Perpanjang masa berlaku kartu " + userID + " dan cetak ulang kartu serta formulir?")) return;
+  postUserAction("/renew", { uid: userID });
+}
+
+const stateActions = { active: "Aktifkan kembali", suspended: "Tangguhkan", blacklisted: "Blokir" };
+
+// changeState moves a holder to another lifecycle state; suspensions may
+// take an end date
+function changeState(userID, state) {
+  const reason = prompt(`${stateActions[state]} ${userID}, alasan:`);
+  if (!reason) return;
+  const params = { uid: userID, state: state, reason: reason };
+  if (state === "suspended") {
+    const until = prompt("Ditangguhkan sampai (YYYY-MM-DD, kosongkan bila tanpa batas):");
+    if (until === null) return;
+    if (until.trim()) params.effective_until = until.trim();
+  }
+  if (state === "blacklisted" && !confirm(`NIK pemegang ${userID} tidak akan bisa didaftarkan lagi. Lanjutkan?`)) return;
+  postUserAction("/state", params);
+}
+
 function downloadGeneratedFile(userID, fileType) {
   const url = `/download?uid=${encodeURIComponent(userID)}&type=${encodeURIComponent(fileType)}`;
   fetch(url)
@@ -12,6 +12,7 @@ document
 
     const formData = new FormData();
     formData.append("file", file);
+    formData.append("operator", localStorage.getItem("operator") || "");
 
     try {
       const response = await fetch("/upload/upsert", {
@@ -21,7 +22,19 @@ document
 
       if (response.ok) {
         const result = await response.json();
-        alert("File uploaded successfully: " + result.message);
+        if (result.Error) {
+          alert(result.Error);
+          return;
+        }
+        let message = `File uploaded successfully: ${result.message} (${result.affected} baris)`;
+        (result.duplicates || []).forEach((d) => {
+          const others = d.Candidates.map((c) => `${c.ID} ${c.Name} [${c.Reasons.join(", ")}]`).join("; ");
+          message += `\nKemungkinan data ganda ${d.ID}: ${others}`;
+        });
+        if (result.warning) {
+          message += `\n⚠️ ${result.warning}`;
+        }
+        alert(message);
       } else {
         alert("Failed to upload file.");
       }